@@ -2,6 +2,7 @@ package gocli
 
 import (
 	"fmt"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -62,26 +63,38 @@ func parseFlag(f string) (string, string) {
 
 // Return the option which matches the flag
 func matchShort(flag string, options []Option) (Option, bool) {
-	for _, opt := range options {
-		if flag == opt.Short {
-			return opt, true
-		}
+	if i := shortIndex(flag, options); i >= 0 {
+		return options[i], true
 	}
 	return Option{}, false
 }
 
 // return the option which matches the flag
 func matchLong(flag string, options []Option) (Option, bool) {
-	for _, opt := range options {
-		if flag == opt.Long {
-			return opt, true
-		}
+	if i := longIndex(flag, options); i >= 0 {
+		return options[i], true
 	}
 	return Option{}, false
 }
 
-func emptyOption(o Option) bool {
-	return o.Short == "" && o.Long == "" && o.Description == "" && o.Type == "" && o.Required == false
+// Returns the index of the option whose short name is the flag, or -1
+func shortIndex(flag string, options []Option) int {
+	for i, opt := range options {
+		if flag == opt.Short {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns the index of the option whose long name is the flag, or -1
+func longIndex(flag string, options []Option) int {
+	for i, opt := range options {
+		if flag == opt.Long {
+			return i
+		}
+	}
+	return -1
 }
 
 // allows for empty int/float values
@@ -169,6 +182,7 @@ func secondCastValue(option Option, value interface{}) (v interface{}, err error
 
 type matchedOption struct {
 	option Option
+	index  int
 	flag   string
 	value  string
 	casted interface{}
@@ -190,11 +204,12 @@ func shortMatchedOption(short string, options []Option) (m matchedOption, err er
 	name, value := parseShort(short)
 	flag := "-" + name
 
-	if opt, ok := matchShort(name, options); !ok {
+	if i := shortIndex(name, options); i < 0 {
 		// if there is no match, return an error
 		err = fmt.Errorf("Unexpected option `%s`", flag)
 	} else {
 		// if there is a match, firstCast the value and return the matchedOption
+		opt := options[i]
 		var casted interface{}
 		casted, err = firstCastValue(opt, value)
 		if err != nil {
@@ -203,6 +218,7 @@ func shortMatchedOption(short string, options []Option) (m matchedOption, err er
 		}
 		m = matchedOption{
 			option: opt,
+			index:  i,
 			flag:   flag,
 			value:  value,
 			casted: casted,
@@ -216,11 +232,12 @@ func longMatchedOption(long string, options []Option) (m matchedOption, err erro
 	name, value := parseLong(long)
 	flag := "--" + name
 
-	if opt, ok := matchLong(name, options); !ok {
+	if i := longIndex(name, options); i < 0 {
 		// if there is no match, return an error
 		err = fmt.Errorf("Unexpected option `%s`", flag)
 	} else {
 		// if there is a match, firstCast the value and return the matchedOption
+		opt := options[i]
 		var casted interface{}
 		casted, err = firstCastValue(opt, value)
 		if err != nil {
@@ -229,6 +246,7 @@ func longMatchedOption(long string, options []Option) (m matchedOption, err erro
 		}
 		m = matchedOption{
 			option: opt,
+			index:  i,
 			flag:   flag,
 			value:  value,
 			casted: casted,
@@ -238,13 +256,64 @@ func longMatchedOption(long string, options []Option) (m matchedOption, err erro
 	return
 }
 
-// match options with cli flags and preform the first cast
-func firstPass(options []Option, argDef Argument, args []string) (map[string]interface{}, map[Option]matchedOption, error) {
+// The kind of a command line arg
+type tokenKind int
+
+const (
+	// A flag, e.g. "--label" or "--label=x"
+	flagToken tokenKind = iota
+
+	// The value of the flag before, e.g. "x" in "--label x"
+	valueToken
+
+	// An argument of the command, including everything after "--"
+	argToken
+)
+
+// A command line arg and its kind
+type token struct {
+	arg  string
+	kind tokenKind
+}
+
+// Split the args into flags, their values and arguments. A flag of an
+// option which takes a value and has no "=" takes the next arg as its value,
+// even if it looks like a flag, e.g. "--help" in "--label --help". "--" is
+// left out, and the args after it are arguments
+func tokenize(options []Option, args []string) []token {
+	tokens := []token{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			for _, rest := range args[i+1:] {
+				tokens = append(tokens, token{arg: rest, kind: argToken})
+			}
+			return tokens
+
+		case isShortFlag(arg) || isLongFlag(arg):
+			tokens = append(tokens, token{arg: arg, kind: flagToken})
+			opt, ok := matchFlag(arg, options)
+			if ok && opt.takesValue() && !strings.Contains(arg, "=") && i+1 < len(args) {
+				i++
+				tokens = append(tokens, token{arg: args[i], kind: valueToken})
+			}
+
+		default:
+			tokens = append(tokens, token{arg: arg, kind: argToken})
+		}
+	}
+	return tokens
+}
+
+// match options with cli flags and preform the first cast. The matched
+// options are in the order of the options
+func firstPass(options []Option, argDef Argument, args []string) (map[string]interface{}, []matchedOption, error) {
 	result := map[string]interface{}{}
-	resultOpt := map[Option]matchedOption{}
+	resultOpt := make([]matchedOption, len(options))
 
-	for _, opt := range options {
-		resultOpt[opt] = matchedOption{casted: nil}
+	for i, opt := range options {
+		resultOpt[i] = matchedOption{index: i}
 		if opt.Short != "" {
 			result[opt.Short] = nil
 		}
@@ -257,65 +326,48 @@ func firstPass(options []Option, argDef Argument, args []string) (map[string]int
 		result[argDef.Name] = nil
 	}
 
-	var prev Option
-	for _, arg := range args {
-		if isShortFlag(arg) {
+	prev := -1
+	for _, tok := range tokenize(options, args) {
+		switch tok.kind {
+		case flagToken:
 			var matched matchedOption
 			var err error
-			matched, err = shortMatchedOption(arg, options)
-			if err != nil {
-				return result, resultOpt, err
-			}
-			prev = matched.option
-			if resultOpt[matched.option].flag != "" {
-				return result, resultOpt, fmt.Errorf("Option entered twice `%s`", matched.option.Name())
+			if isShortFlag(tok.arg) {
+				matched, err = shortMatchedOption(tok.arg, options)
+			} else {
+				matched, err = longMatchedOption(tok.arg, options)
 			}
-			resultOpt[matched.option] = matched
-
-		} else if isLongFlag(arg) {
-			var matched matchedOption
-			var err error
-			matched, err = longMatchedOption(arg, options)
 			if err != nil {
 				return result, resultOpt, err
 			}
-			prev = matched.option
-			if resultOpt[matched.option].flag != "" {
+			prev = matched.index
+			if resultOpt[matched.index].flag != "" {
 				return result, resultOpt, fmt.Errorf("Option entered twice `%s`", matched.option.Name())
 			}
-			resultOpt[matched.option] = matched
+			resultOpt[matched.index] = matched
 
-		} else if !emptyOption(prev) && noValue(resultOpt[prev]) {
-			// this block runs if the prev arg was a flag and the value for the flag is empty
-			// assume this arg is the value for the previous flag
+		case valueToken:
+			// the value of the previous flag
 			prevMatched := resultOpt[prev]
 			flag := prevMatched.flag
 
 			// cast the value
-			casted, err := firstCastValue(prev, arg)
+			casted, err := firstCastValue(prevMatched.option, tok.arg)
 			if err != nil {
 				return result, resultOpt, fmt.Errorf("Error parsing `%s`: %s", flag, err)
 			}
 
 			// save the casted value
-			prevMatched.value = arg
+			prevMatched.value = tok.arg
 			prevMatched.casted = casted
-
 			resultOpt[prev] = prevMatched
-			prev = Option{}
-
-		} else {
-			// this block runs if the previous arg was not an empty flag
-			// assume this arg is an expected arg for the command
 
+		case argToken:
 			// check if the arg is already defined or if not expected
 			if v := result[argDef.Name]; v != nil || argDef.Name == "" {
-				return result, resultOpt, fmt.Errorf("Received unknown argument '%s'", arg)
+				return result, resultOpt, fmt.Errorf("Received unknown argument '%s'", tok.arg)
 			}
-
-			result[argDef.Name] = arg
-			prev = Option{}
-
+			result[argDef.Name] = tok.arg
 		}
 	}
 
@@ -327,6 +379,31 @@ func firstPass(options []Option, argDef Argument, args []string) (map[string]int
 	return result, resultOpt, nil
 }
 
+// Parse the args of an incomplete command line, e.g. while completing it.
+// Errors are ignored and options without values are left out
+func parsePartialArgs(options []Option, argDef Argument, args []string) map[string]interface{} {
+	result, resultOpt, _ := firstPass(options, argDef, args)
+
+	for i, opt := range options {
+		matched := resultOpt[i]
+		if matched.flag == "" && opt.Type != "bool" {
+			continue
+		}
+		casted, err := secondCastValue(opt, matched.casted)
+		if err != nil {
+			continue
+		}
+		if opt.Long != "" {
+			result[opt.Long] = casted
+		}
+		if opt.Short != "" {
+			result[opt.Short] = casted
+		}
+	}
+
+	return result
+}
+
 // read in string cli args and parse them
 func ParseArgs(options []Option, argDef Argument, args []string) (map[string]interface{}, error) {
 
@@ -340,8 +417,8 @@ func ParseArgs(options []Option, argDef Argument, args []string) (map[string]int
 
 	// check that all the required options have values
 	missing := []string{}
-	for _, opt := range options {
-		matched := resultOpt[opt]
+	for i, opt := range options {
+		matched := resultOpt[i]
 		// noValue always returns false for booleans
 		if noValue(matched) && opt.Required {
 			// required values that were not matched
@@ -354,8 +431,8 @@ func ParseArgs(options []Option, argDef Argument, args []string) (map[string]int
 	}
 
 	// second cast the matched options as they are placed into 'result'
-	for _, opt := range options {
-		matched := resultOpt[opt]
+	for i, opt := range options {
+		matched := resultOpt[i]
 		casted, err := secondCastValue(opt, matched.casted)
 		if err != nil && matched.flag != "" {
 			return result, fmt.Errorf("Error parsing `%s`: %s", matched.flag, err)
@@ -370,5 +447,35 @@ func ParseArgs(options []Option, argDef Argument, args []string) (map[string]int
 		}
 	}
 
+	// check the experimental options which were used. The warnings of the
+	// deprecated options are printed by the command which runs, see
+	// deprecationWarnings
+	for i, opt := range options {
+		matched := resultOpt[i]
+		if matched.flag != "" && opt.Experimental && !experimentalEnabled(result) {
+			return result, fmt.Errorf("Option `%s` is experimental. Set %s=1 or pass --%s to enable it.", matched.flag, ExperimentalEnv, ExperimentalOption.Long)
+		}
+	}
+
 	return result, nil
 }
+
+// Returns the warnings of the deprecated options which the args use
+func deprecationWarnings(options []Option, args []string) []string {
+	warnings := []string{}
+	for _, tok := range tokenize(options, args) {
+		if tok.kind != flagToken {
+			continue
+		}
+		if opt, ok := matchFlag(tok.arg, options); ok && opt.Deprecated != "" {
+			flag, _ := parseFlag(tok.arg)
+			warnings = append(warnings, fmt.Sprintf("Option `%s` is deprecated. %s", flag, opt.Deprecated))
+		}
+	}
+	return warnings
+}
+
+// Print a warning to stderr
+func warn(msg string) {
+	fmt.Fprintln(os.Stderr, Yellow("Warning: "+msg))
+}
@@ -319,7 +319,7 @@ func TestShortMatchedOption(t *testing.T) {
 	o2 := Option{Short: "t", Type: "string"}
 	options := []Option{o1, o2}
 	m, err := shortMatchedOption(short, options)
-	expectedM := matchedOption{option: o2, flag: "-t", value: "test", casted: "test"}
+	expectedM := matchedOption{option: o2, index: 1, flag: "-t", value: "test", casted: "test"}
 	if !reflect.DeepEqual(m, expectedM) || err != nil {
 		t.Errorf("shortMatchedOption failed to match. matched: %+v, err: %s\n", m, err)
 	}
@@ -330,7 +330,7 @@ func TestShortMatchedOption(t *testing.T) {
 	o2 = Option{Short: "t", Type: "string"}
 	options = []Option{o1, o2}
 	m, err = shortMatchedOption(short, options)
-	expectedM = matchedOption{option: o2, flag: "-t", value: "", casted: nil}
+	expectedM = matchedOption{option: o2, index: 1, flag: "-t", value: "", casted: nil}
 	if !reflect.DeepEqual(m, expectedM) || err != nil {
 		t.Errorf("shortMatchedOption failed to match no value. matched: %+v, err: %s\n", m, err)
 	}
@@ -354,7 +354,7 @@ func TestLongMatchedOption(t *testing.T) {
 	options := []Option{o1, o2}
 
 	m, err := longMatchedOption(long, options)
-	expectedM := matchedOption{option: o2, flag: "--t", value: "2", casted: 2}
+	expectedM := matchedOption{option: o2, index: 1, flag: "--t", value: "2", casted: 2}
 	if !reflect.DeepEqual(m, expectedM) || err != nil {
 		t.Errorf("longMatchedOption failed to match. matched: %+v, err: %s\n", m, err)
 	}
@@ -366,7 +366,7 @@ func TestLongMatchedOption(t *testing.T) {
 	options = []Option{o1, o2}
 
 	m, err = longMatchedOption(long, options)
-	expectedM = matchedOption{option: o2, flag: "--t", value: "", casted: nil}
+	expectedM = matchedOption{option: o2, index: 1, flag: "--t", value: "", casted: nil}
 	if !reflect.DeepEqual(m, expectedM) || err != nil {
 		t.Errorf("longMatchedOption failed to match no value. matched: %+v, err: %s\n", m, err)
 	}
@@ -382,3 +382,85 @@ func TestLongMatchedOption(t *testing.T) {
 		t.Errorf("longMatchedOption match when it shouldn't have. matched: %+v, err: %s\n", m, err)
 	}
 }
+
+func TestParseArgsDoubleDash(t *testing.T) {
+	options := []Option{{Short: "v", Type: "bool"}}
+	argDef := Argument{Name: "file"}
+
+	// everything after "--" is an argument
+	result, err := ParseArgs(options, argDef, []string{"-v", "--", "-x"})
+	if err != nil || result["file"] != "-x" || result["v"] != true {
+		t.Errorf("ParseArgs did not treat the args after '--' as arguments. result: %+v, err: %s\n", result, err)
+	}
+
+	// only the first "--" is special
+	result, err = ParseArgs(options, argDef, []string{"--", "--"})
+	if err != nil || result["file"] != "--" {
+		t.Errorf("ParseArgs did not treat a second '--' as an argument. result: %+v, err: %s\n", result, err)
+	}
+
+	_, err = ParseArgs(options, argDef, []string{"--", "a", "b"})
+	if err == nil {
+		t.Errorf("ParseArgs accepted two arguments after '--'\n")
+	}
+}
+
+func TestParseArgsFlagValue(t *testing.T) {
+	options := []Option{{Short: "l", Long: "label", Type: "string"}, HelpOption}
+	cmd := Command{Name: "run", Options: &[]Option{options[0]}}
+
+	// an option which takes a value takes the next arg, even if it looks
+	// like a flag, so ParseArgs and wantsHelp agree
+	args := []string{"--label", "--help"}
+	result, err := ParseArgs(options, Argument{}, args)
+	if err != nil || result["label"] != "--help" || result["help"] != false || wantsHelp(&cmd, args) {
+		t.Errorf("ParseArgs(%q) returned %+v, %v", args, result, err)
+	}
+
+	args = []string{"-l", "-h"}
+	result, err = ParseArgs(options, Argument{}, args)
+	if err != nil || result["l"] != "-h" || wantsHelp(&cmd, args) {
+		t.Errorf("ParseArgs(%q) returned %+v, %v", args, result, err)
+	}
+
+	args = []string{"--label=x", "--help"}
+	result, err = ParseArgs(options, Argument{}, args)
+	if err != nil || result["label"] != "x" || result["help"] != true || !wantsHelp(&cmd, args) {
+		t.Errorf("ParseArgs(%q) returned %+v, %v", args, result, err)
+	}
+}
+
+func TestParseArgsExperimentalDeprecated(t *testing.T) {
+	t.Setenv(ExperimentalEnv, "")
+
+	options := []Option{
+		{Long: "turbo", Type: "bool", Experimental: true},
+		{Long: "old", Type: "string", Deprecated: "Use --new instead."},
+		ExperimentalOption,
+	}
+
+	_, err := ParseArgs(options, Argument{}, []string{"--turbo"})
+	if err == nil || !strings.Contains(err.Error(), "Option `--turbo` is experimental.") {
+		t.Errorf("ParseArgs did not reject an experimental option. err: %v\n", err)
+	}
+
+	result, err := ParseArgs(options, Argument{}, []string{"--turbo", "--experimental"})
+	if err != nil || result["turbo"] != true {
+		t.Errorf("ParseArgs did not enable the experimental option. result: %+v, err: %v\n", result, err)
+	}
+
+	// unused experimental options are not an error
+	if _, err = ParseArgs(options, Argument{}, []string{}); err != nil {
+		t.Errorf("ParseArgs rejected args without experimental options. err: %s\n", err)
+	}
+
+	// the warning is left to the command which runs
+	out := captureStderr(t, func() { result, err = ParseArgs(options, Argument{}, []string{"--old", "x"}) })
+	if err != nil || result["old"] != "x" || out != "" {
+		t.Errorf("ParseArgs did not parse a deprecated option silently. result: %+v, err: %v, printed: %q\n", result, err, out)
+	}
+	warnings := deprecationWarnings(options, []string{"--old=x", "--turbo"})
+	if len(warnings) != 1 || warnings[0] != "Option `--old` is deprecated. Use --new instead." {
+		t.Errorf("deprecationWarnings returned %q\n", warnings)
+	}
+}
@@ -1,127 +1,643 @@
 package gocli
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/term"
 )
 
 type BashResult struct {
 	Stdout string `json:"stdout"`
 	Stderr string `json:"stderr"`
 	Err    error  `json:"err"`
+
+	// The command which was run
+	Cmd string `json:"cmd"`
+
+	// Exit code of the command, or -1 if it did not exit normally (e.g. it
+	// was killed by a signal or did not start)
+	ExitCode int `json:"exitCode"`
+
+	// Name of the signal which killed the command, e.g. "SIGKILL", if any
+	Signal string `json:"signal,omitempty"`
+
+	// Process ID of the shell, or 0 if it did not start
+	Pid int `json:"pid"`
+
+	StartedAt time.Time     `json:"startedAt"`
+	Duration  time.Duration `json:"duration"`
+
+	// The stdout and stderr together, in the order the command wrote them.
+	// Only captured if Runner.Combined is set
+	Combined string `json:"combined,omitempty"`
+}
+
+// Identifies the stream which a command wrote output to
+type OutputStream int
+
+const (
+	StdoutStream OutputStream = iota + 1
+	StderrStream
+)
+
+// Returns "stdout" or "stderr"
+func (s OutputStream) String() string {
+	switch s {
+	case StdoutStream:
+		return "stdout"
+	case StderrStream:
+		return "stderr"
+	}
+	return ""
+}
+
+// A line of the output of a command, passed to Runner.OnLine
+type OutputLine struct {
+	Stream OutputStream
+
+	// The line without the line break
+	Text string
+
+	// When the line was read
+	Time time.Time
+}
+
+// Returns true if the command ran and exited with code 0
+func (res BashResult) Success() bool {
+	return res.Err == nil && res.ExitCode == 0
+}
+
+// Marshal the result to JSON, with Err as a string (or null). The duration
+// is in nanoseconds
+func (res BashResult) MarshalJSON() ([]byte, error) {
+	type result BashResult
+	var err *string
+	if res.Err != nil {
+		msg := res.Err.Error()
+		err = &msg
+	}
+	return json.Marshal(struct {
+		result
+		Err *string `json:"err"`
+	}{result(res), err})
+}
+
+// Unmarshal a result marshaled by MarshalJSON. Err is restored as an error
+// with the same message
+func (res *BashResult) UnmarshalJSON(b []byte) error {
+	type result BashResult
+	aux := struct {
+		*result
+		Err *string `json:"err"`
+	}{result: (*result)(res)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	res.Err = nil
+	if aux.Err != nil {
+		res.Err = errors.New(*aux.Err)
+	}
+	return nil
+}
+
+// Returned in BashResult.Err when a command is killed because its deadline
+// passed. Check for it with errors.Is(res.Err, ErrTimeout)
+var ErrTimeout = errors.New("Command timed out")
+
+// Shells which a Runner can run commands with
+const (
+	ShellBash = "bash"
+	ShellSh   = "sh"
+	ShellZsh  = "zsh"
+
+	// Run the command without a shell. It is split into words like a line of
+	// the interactive shell, i.e. quotes and backslashes are respected, and
+	// the first word is the executable
+	ShellNone = "none"
+)
+
+// Runs commands with a shell. The zero value runs them like Bash: with
+// "bash -e", in the current directory, with the environment of the process
+// and without stdin
+type Runner struct {
+	// Shell which runs the commands, ShellBash by default
+	Shell string
+
+	// Working directory of the commands. The current directory by default
+	Dir string
+
+	// Environment variables of the commands, as "KEY=value". They are added
+	// to the environment of the process, overriding the variables it already
+	// has, unless ReplaceEnv is set
+	Env []string
+
+	// Run the commands with Env only, instead of the environment of the
+	// process
+	ReplaceEnv bool
+
+	// The stdin of the commands. If it is nil, Input is used instead
+	Stdin io.Reader
+
+	// The stdin of the commands as a string, used if Stdin is nil
+	Input string
+
+	// Toggles of the shell: "set -o pipefail", "set -u" and "set -x". They
+	// have no effect with ShellNone, and pipefail needs a shell which
+	// supports it (older versions of sh do not)
+	Pipefail bool
+	NoUnset  bool
+	Trace    bool
+
+	// Stream the stdout and stderr of the command to the stdout and stderr
+	// of the process while it runs, with Label before every line
+	StreamStdout bool
+	StreamStderr bool
+	Label        string
+
+	// Labels of the streamed stdout and stderr lines, which replace Label
+	StdoutLabel string
+	StderrLabel string
+
+	// Colors of the labels of the streamed stdout and stderr lines, e.g.
+	// gocli.Cyan. They are only applied if the lines are printed to a
+	// terminal
+	StdoutColor func(s string) string
+	StderrColor func(s string) string
+
+	// Print the time before every streamed line in this layout, e.g.
+	// "15:04:05.000" (see time.Layout)
+	TimestampFormat string
+
+	// Writers which the stdout and stderr are copied to while the command
+	// runs, e.g. a log file. They may be the same writer. An error of a
+	// writer stops the copy and is returned in BashResult.Err
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Called with every line of the stdout and stderr while the command
+	// runs. It is not called concurrently
+	OnLine func(line OutputLine)
+
+	// Capture the stdout and stderr together in BashResult.Combined
+	Combined bool
+}
+
+// Run a command and return the stdout & stderr in a BashResult struct
+func (r Runner) Run(cmd string) BashResult {
+	return r.RunContext(context.Background(), cmd)
+}
+
+// Run a command which is killed, with the processes it started, when ctx is
+// done (see BashContext)
+func (r Runner) RunContext(ctx context.Context, cmd string) BashResult {
+	args, err := r.shellArgs(cmd)
+	if err != nil {
+		return BashResult{Cmd: cmd, ExitCode: -1, StartedAt: time.Now(), Err: err}
+	}
+	return r.run(ctx, cmd, args)
+}
+
+// Run a command which is killed, with the processes it started, if it takes
+// longer than timeout (see BashTimeout)
+func (r Runner) RunTimeout(cmd string, timeout time.Duration) BashResult {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return r.RunContext(ctx, cmd)
+}
+
+// Run an executable with args, without a shell, so the args are passed as
+// they are. The Shell and its toggles are ignored; the other options apply.
+// BashResult.Cmd is the equivalent shell command
+func (r Runner) Exec(name string, args ...string) BashResult {
+	return r.ExecContext(context.Background(), name, args...)
+}
+
+// Same as Exec, but the executable is killed, with the processes it started,
+// when ctx is done (see BashContext)
+func (r Runner) ExecContext(ctx context.Context, name string, args ...string) BashResult {
+	return r.run(ctx, QuoteArgs(append([]string{name}, args...)...), append([]string{name}, args...))
+}
+
+// Returns the executable and the args which run cmd: the shell with its
+// options, or the words of cmd with ShellNone
+func (r Runner) shellArgs(cmd string) ([]string, error) {
+	shell := r.Shell
+	if shell == "" {
+		shell = ShellBash
+	}
+
+	if shell == ShellNone {
+		words, err := splitCommandLine(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("Empty command.")
+		}
+		return words, nil
+	}
+
+	args := []string{shell, "-e"}
+	if r.NoUnset {
+		args = append(args, "-u")
+	}
+	if r.Trace {
+		args = append(args, "-x")
+	}
+	if r.Pipefail {
+		args = append(args, "-o", "pipefail")
+	}
+	return append(args, "-c", cmd), nil
+}
+
+// Returns the process which runs the executable args[0] with the rest of
+// the args
+func (r Runner) command(args []string) *exec.Cmd {
+	c := exec.Command(args[0], args[1:]...)
+	c.Dir = r.Dir
+	if r.ReplaceEnv {
+		c.Env = append([]string{}, r.Env...)
+	} else if len(r.Env) > 0 {
+		// later entries take precedence
+		c.Env = append(os.Environ(), r.Env...)
+	}
+	if r.Stdin != nil {
+		c.Stdin = r.Stdin
+	} else if r.Input != "" {
+		c.Stdin = strings.NewReader(r.Input)
+	}
+	return c
 }
 
 // Run a bash command and return the stdout & stderr in a
 // BashResult struct
 func Bash(cmd string) (res BashResult) {
-	res.Stdout, res.Stderr, res.Err = runBash(cmd, false, false, "")
-	return
+	return BashContext(context.Background(), cmd)
 }
 
 // Run a bash command, stream the stdout and/or stderr, and
 // return the stdout & stderr in a BashResult stuct
 func BashStream(cmd string, stdout bool, stderr bool) (res BashResult) {
-	res.Stdout, res.Stderr, res.Err = runBash(cmd, stdout, stderr, "")
-	return
+	return BashStreamContext(context.Background(), cmd, stdout, stderr)
 }
 
 // Run a bash command, stream the stdout and/or stderr with a custom label,
 // and return the stdout & stderr in a BashResult struct
 func BashStreamLabel(cmd string, stdout bool, stderr bool, label string) (res BashResult) {
-	res.Stdout, res.Stderr, res.Err = runBash(cmd, stdout, stderr, label)
-	return
+	return BashStreamLabelContext(context.Background(), cmd, stdout, stderr, label)
+}
+
+// Run a bash command which is killed, with the processes it started, when
+// ctx is done. The output captured until then is returned. Err is ErrTimeout
+// if the deadline of ctx passed, or ctx.Err() if ctx was canceled. The
+// command runs in its own process group, so Ctrl-C in the terminal does not
+// reach it; cancel ctx instead, e.g. with signal.NotifyContext
+func BashContext(ctx context.Context, cmd string) (res BashResult) {
+	return Runner{}.RunContext(ctx, cmd)
 }
 
-// Run a bash command with special options.
-//
-// "cmd" is the bash command, "sOut" indicates whether to stream the stdout,
-// "sErr" indicates whether to stream the stderr, and "l" is the label of
-// any stream
-func runBash(cmd string, sOut bool, sErr bool, l string) (stdout string, stderr string, err error) {
-	c := exec.Command(fmt.Sprintf(`bash`), "-c", "-e", cmd)
+// Same as BashStream, but the command is killed when ctx is done (see
+// BashContext)
+func BashStreamContext(ctx context.Context, cmd string, stdout bool, stderr bool) (res BashResult) {
+	return Runner{StreamStdout: stdout, StreamStderr: stderr}.RunContext(ctx, cmd)
+}
 
-	outPipe, err := c.StdoutPipe()
-	if err != nil {
+// Same as BashStreamLabel, but the command is killed when ctx is done (see
+// BashContext)
+func BashStreamLabelContext(ctx context.Context, cmd string, stdout bool, stderr bool, label string) (res BashResult) {
+	return Runner{StreamStdout: stdout, StreamStderr: stderr, Label: label}.RunContext(ctx, cmd)
+}
+
+// Run a bash command which is killed, with the processes it started, if it
+// takes longer than timeout. Err is ErrTimeout in that case
+func BashTimeout(cmd string, timeout time.Duration) (res BashResult) {
+	return Runner{}.RunTimeout(cmd, timeout)
+}
+
+// Same as BashStream, but the command is killed if it takes longer than
+// timeout (see BashTimeout)
+func BashStreamTimeout(cmd string, stdout bool, stderr bool, timeout time.Duration) (res BashResult) {
+	return Runner{StreamStdout: stdout, StreamStderr: stderr}.RunTimeout(cmd, timeout)
+}
+
+// Run an executable with args, without a shell, and return the stdout &
+// stderr in a BashResult struct. The args are passed as they are, so they
+// need no quoting
+func Exec(name string, args ...string) BashResult {
+	return Runner{}.Exec(name, args...)
+}
+
+// Run the executable args[0] with the rest of the args and the options of
+// the runner. "cmd" is the command which is reported in the result. The
+// process and the processes it started are killed when ctx is done
+func (r Runner) run(ctx context.Context, cmd string, args []string) (res BashResult) {
+	res.Cmd = cmd
+	res.ExitCode = -1
+	res.StartedAt = time.Now()
+
+	if err := ctx.Err(); err != nil {
+		res.Err = contextError(err)
 		return
 	}
 
-	errPipe, err := c.StderrPipe()
+	c := r.command(args)
+	// a command which ctx can kill runs in its own process group, so that
+	// the processes it starts are killed with it. Other commands stay in the
+	// group of the caller, which receives Ctrl-C from the terminal with them
+	if ctx.Done() != nil {
+		setProcessGroup(c)
+	}
+
+	// the output is copied from pipes rather than by exec, whose Wait would
+	// wait for the processes which the command left running in the
+	// background to close the pipes
+	stdout, stderr := &strings.Builder{}, &strings.Builder{}
+	var combined *strings.Builder
+	if r.Combined {
+		combined = &strings.Builder{}
+	}
+	var output sync.Mutex
+	outLines := r.lines(StdoutStream)
+	errLines := r.lines(StderrStream)
+
+	outRead, outWrite, err := os.Pipe()
 	if err != nil {
+		res.Err = err
 		return
 	}
+	errRead, errWrite, err := os.Pipe()
+	if err != nil {
+		outRead.Close()
+		outWrite.Close()
+		res.Err = err
+		return
+	}
+	defer outRead.Close()
+	defer errRead.Close()
+	c.Stdout, c.Stderr = outWrite, errWrite
 
-	c.Start()
-
-	errs := make(chan error)
+	err = c.Start()
+	// the command has its own copies of the write ends
+	outWrite.Close()
+	errWrite.Close()
+	if err != nil {
+		res.Err = err
+		res.Duration = time.Since(res.StartedAt)
+		return
+	}
+	res.Pid = c.Process.Pid
 
+	// kill the command when the context is done
+	var lock sync.Mutex
+	killed, exited := false, false
+	finished := make(chan struct{})
+	defer close(finished)
 	go func() {
-		var err error
-		stdout, err = readShell(bufio.NewReader(outPipe), sOut, l)
-		if err != nil {
-			errs <- err
-		}
-	}()
-	go func() {
-		var err error
-		stderr, err = readShell(bufio.NewReader(errPipe), sErr, l)
-		if err != nil {
-			errs <- err
+		select {
+		case <-ctx.Done():
+			lock.Lock()
+			defer lock.Unlock()
+			if !exited {
+				killed = killProcessGroup(c) == nil
+			}
+		case <-finished:
 		}
 	}()
 
-	// wait for the above goroutines to complete
+	activity := &outputActivity{}
+	copied := make(chan error, 2)
+	go copyOutput(outputWriter(&output, stdout, r.Stdout, combined, outLines), outRead, activity, copied)
+	go copyOutput(outputWriter(&output, stderr, r.Stderr, combined, errLines), errRead, activity, copied)
+
 	err = c.Wait()
+	lock.Lock()
+	exited = true
+	lock.Unlock()
+
+	if copyErr := waitForOutput(copied, []*os.File{outRead, errRead}, activity); err == nil {
+		err = copyErr
+	}
+
+	for _, lines := range []*lineWriter{outLines, errLines} {
+		if lines != nil {
+			lines.flush()
+		}
+	}
+	res.Stdout, res.Stderr = stdout.String(), stderr.String()
+	if combined != nil {
+		res.Combined = combined.String()
+	}
+
+	res.Duration = time.Since(res.StartedAt)
+	if state := c.ProcessState; state != nil {
+		res.ExitCode = state.ExitCode()
+		res.Signal = exitSignal(state)
+	}
 
-	// pick up any errors
-	select {
-	case err = <-errs:
-	default:
+	lock.Lock()
+	defer lock.Unlock()
+	if killed {
+		err = contextError(ctx.Err())
 	}
 
+	res.Err = err
 	return
 }
 
-// go routine to parse the output of a shell
-func readShell(r *bufio.Reader, stream bool, label string) (output string, err error) {
-	strs := make(chan string)
-	errs := make(chan error)
-	done := make(chan int)
+// Returns ErrTimeout for an expired deadline, or the error of the context
+func contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return err
+}
+
+// How long the output is still read after the command exited, while it keeps
+// coming. Processes which the command left running in the background, e.g.
+// "server &", may keep the pipes open; their later output is not read
+var outputGrace = 100 * time.Millisecond
 
-	go func() {
-		defer func() { done <- 0 }()
-		for {
-			line, _, err := r.ReadLine()
-
-			if err != nil {
-				if err != io.EOF {
-					errs <- err
-				}
-				break
+// What the copies of the output are doing, see waitForOutput
+type outputActivity struct {
+	// Number of copies which wait for output in Read
+	reading int32
+
+	// Number of bytes which were read
+	read int64
+}
+
+// Copy the output of the command from a pipe to dst. The pipe is closed
+// afterwards, so that the command does not block on a full pipe if dst failed
+func copyOutput(dst io.Writer, pipe *os.File, activity *outputActivity, copied chan<- error) {
+	_, err := io.Copy(dst, activityReader{pipe, activity})
+	pipe.Close()
+	copied <- err
+}
+
+// Wait until the output of the pipes is copied, once the command exited. The
+// pipes are closed when every copy waited for output in Read for outputGrace,
+// without any coming. A copy which is busy writing, e.g. to a slow OnLine,
+// keeps the pipes open, so that the output which is still in them is read.
+// Returns the first error of the copies
+func waitForOutput(copied <-chan error, pipes []*os.File, activity *outputActivity) (err error) {
+	last := atomic.LoadInt64(&activity.read)
+	timer := time.NewTimer(outputGrace)
+	defer timer.Stop()
+
+	closed := false
+	for n := 0; n < len(pipes); {
+		select {
+		case copyErr := <-copied:
+			n++
+			// the pipes which were closed below
+			if copyErr != nil && !(closed && errors.Is(copyErr, os.ErrClosed)) && err == nil {
+				err = copyErr
+			}
+		case <-timer.C:
+			now := atomic.LoadInt64(&activity.read)
+			idle := int(atomic.LoadInt32(&activity.reading)) == len(pipes)-n
+			if now != last || !idle {
+				last = now
+				timer.Reset(outputGrace)
+				continue
+			}
+			closed = true
+			for _, pipe := range pipes {
+				pipe.Close()
 			}
-			strs <- string(line)
 		}
-	}()
+	}
+	return err
+}
 
-	v := true
-	for v {
-		select {
+// Records the activity of a copy of the output
+type activityReader struct {
+	r        io.Reader
+	activity *outputActivity
+}
+
+func (r activityReader) Read(p []byte) (int, error) {
+	atomic.AddInt32(&r.activity.reading, 1)
+	n, err := r.r.Read(p)
+	// the bytes are counted before the copy leaves Read, so that a check
+	// in between sees either of them
+	atomic.AddInt64(&r.activity.read, int64(n))
+	atomic.AddInt32(&r.activity.reading, -1)
+	return n, err
+}
+
+// Returns the lineWriter which prints the lines of a stream and passes them
+// to OnLine, or nil if there is nothing to do with them
+func (r Runner) lines(stream OutputStream) *lineWriter {
+	print := (stream == StdoutStream && r.StreamStdout) || (stream == StderrStream && r.StreamStderr)
+	if !print && r.OnLine == nil {
+		return nil
+	}
 
-		case err = <-errs:
-			v = false
-		case <-done:
-			v = false
-		case s := <-strs:
-			if stream {
-				fmt.Printf("%s%s%s", label, s, Sep())
+	out, label, paint := os.Stdout, r.StdoutLabel, r.StdoutColor
+	if stream == StderrStream {
+		out, label, paint = os.Stderr, r.StderrLabel, r.StderrColor
+	}
+	if label == "" {
+		label = r.Label
+	}
+	if !term.IsTerminal(int(out.Fd())) {
+		paint = nil
+	}
+
+	return &lineWriter{emit: func(text string) {
+		now := time.Now()
+		if print {
+			prefix := label
+			if r.TimestampFormat != "" {
+				prefix = now.Format(r.TimestampFormat) + " " + prefix
+			}
+			if paint != nil && prefix != "" {
+				prefix = paint(prefix)
 			}
-			output = fmt.Sprintf("%s%s\n", output, s)
-		default:
+			fmt.Fprintf(out, "%s%s%s", prefix, text, Sep())
 		}
+		if r.OnLine != nil {
+			r.OnLine(OutputLine{Stream: stream, Text: text, Time: now})
+		}
+	}}
+}
+
+// Returns the writer which a stream of the command is written to. It writes
+// to the buffer which captures the stream, and to the writer of the runner,
+// the combined output and the lines if they are not nil. The lock is shared
+// by the streams, so that their writes do not overlap
+func outputWriter(lock *sync.Mutex, buffer *strings.Builder, w io.Writer, combined *strings.Builder, lines *lineWriter) io.Writer {
+	writers := []io.Writer{buffer}
+	if w != nil {
+		writers = append(writers, w)
+	}
+	if combined != nil {
+		writers = append(writers, combined)
+	}
+	if lines != nil {
+		writers = append(writers, lines)
+	}
+	return &lockedWriter{lock: lock, w: io.MultiWriter(writers...)}
+}
+
+// Serializes the writes to a writer
+type lockedWriter struct {
+	lock *sync.Mutex
+	w    io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.w.Write(p)
+}
+
+// Splits the output written to it into lines, which are passed to emit
+// without the line break ("\n" or "\r\n"). Lines can be of any length. The
+// last line is passed by flush if it does not end with a line break
+type lineWriter struct {
+	emit    func(line string)
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			break
+		}
+		line := p[:i]
+		if len(w.partial) > 0 {
+			line = append(w.partial, line...)
+		}
+		w.emit(strings.TrimSuffix(string(line), "\r"))
+		w.partial = w.partial[:0]
+		p = p[i+1:]
+	}
+	w.partial = append(w.partial, p...)
+	return n, nil
+}
+
+// Pass the last line if it did not end with a line break
+func (w *lineWriter) flush() {
+	if len(w.partial) > 0 {
+		w.emit(strings.TrimSuffix(string(w.partial), "\r"))
+		w.partial = w.partial[:0]
 	}
-	return
 }
 
 func Sep() string {
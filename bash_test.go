@@ -0,0 +1,405 @@
+package gocli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBash(t *testing.T) {
+	res := Bash("echo out; echo err >&2")
+	if res.Err != nil || res.Stdout != "out\n" || res.Stderr != "err\n" {
+		t.Errorf("Bash returned %+v", res)
+	}
+
+	res = Bash("exit 3")
+	if res.Err == nil {
+		t.Errorf("Bash did not return an error for a failed command")
+	}
+}
+
+func TestBashTimeout(t *testing.T) {
+	// the background sleep keeps the pipes open unless the whole process
+	// group is killed
+	start := time.Now()
+	res := BashTimeout("echo started; sleep 10 & sleep 10", 200*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("BashTimeout returned after %s", elapsed)
+	}
+	if !errors.Is(res.Err, ErrTimeout) {
+		t.Errorf("BashTimeout returned the error %v", res.Err)
+	}
+	if res.Stdout != "started\n" {
+		t.Errorf("BashTimeout did not return the partial output: %q", res.Stdout)
+	}
+
+	// commands which finish in time are not affected
+	res = BashTimeout("echo done", 5*time.Second)
+	if res.Err != nil || res.Stdout != "done\n" {
+		t.Errorf("BashTimeout returned %+v", res)
+	}
+}
+
+func TestBashContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+	}()
+	res := BashContext(ctx, "sleep 10")
+	if !errors.Is(res.Err, context.Canceled) {
+		t.Errorf("BashContext returned the error %v", res.Err)
+	}
+
+	// a context which is already done does not run the command
+	res = BashContext(ctx, "echo ran")
+	if !errors.Is(res.Err, context.Canceled) || res.Stdout != "" {
+		t.Errorf("BashContext ran a command with a canceled context: %+v", res)
+	}
+}
+
+func TestBashResult(t *testing.T) {
+	res := Bash("echo ok")
+	if !res.Success() || res.ExitCode != 0 || res.Signal != "" || res.Cmd != "echo ok" {
+		t.Errorf("Bash returned %+v", res)
+	}
+	if res.Pid == 0 || res.StartedAt.IsZero() || res.Duration <= 0 {
+		t.Errorf("Bash did not record the process: %+v", res)
+	}
+
+	res = Bash("exit 3")
+	if res.Success() || res.ExitCode != 3 {
+		t.Errorf("Bash returned the exit code %d", res.ExitCode)
+	}
+}
+
+func TestBashResultSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are not reported on Windows")
+	}
+	res := BashTimeout("sleep 10", 100*time.Millisecond)
+	if res.ExitCode != -1 || res.Signal != "SIGKILL" {
+		t.Errorf("BashTimeout returned the exit code %d and the signal %q", res.ExitCode, res.Signal)
+	}
+}
+
+func TestBashResultJSON(t *testing.T) {
+	res := Bash("echo out; exit 2")
+	b, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := map[string]interface{}{}
+	json.Unmarshal(b, &fields)
+	if fields["err"] != res.Err.Error() || fields["exitCode"] != 2.0 || fields["stdout"] != "out\n" {
+		t.Errorf("BashResult was marshaled as %s", b)
+	}
+
+	var decoded BashResult
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Err == nil || decoded.Err.Error() != res.Err.Error() || decoded.ExitCode != 2 || !decoded.StartedAt.Equal(res.StartedAt) {
+		t.Errorf("BashResult was unmarshaled as %+v", decoded)
+	}
+
+	b, _ = json.Marshal(Bash("true"))
+	if !strings.Contains(string(b), `"err":null`) {
+		t.Errorf("BashResult without an error was marshaled as %s", b)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	dir := t.TempDir()
+	res := Runner{Dir: dir}.Run("pwd")
+	if got, _ := filepath.EvalSymlinks(strings.TrimSpace(res.Stdout)); got != mustEvalSymlinks(t, dir) {
+		t.Errorf("Runner ran in %q instead of %q", res.Stdout, dir)
+	}
+
+	t.Setenv("GOCLI_TEST_INHERITED", "inherited")
+	res = Runner{Env: []string{"GOCLI_TEST_ADDED=added"}}.Run(`echo "$GOCLI_TEST_INHERITED $GOCLI_TEST_ADDED"`)
+	if res.Stdout != "inherited added\n" {
+		t.Errorf("Runner did not merge the environment: %q", res.Stdout)
+	}
+	res = Runner{Env: []string{"GOCLI_TEST_INHERITED=overridden"}}.Run(`echo "$GOCLI_TEST_INHERITED"`)
+	if res.Stdout != "overridden\n" {
+		t.Errorf("Runner did not override the environment: %q", res.Stdout)
+	}
+	res = Runner{Env: []string{"GOCLI_TEST_ADDED=added"}, ReplaceEnv: true}.Run(`echo "$GOCLI_TEST_INHERITED $GOCLI_TEST_ADDED"`)
+	if res.Stdout != " added\n" {
+		t.Errorf("Runner did not replace the environment: %q", res.Stdout)
+	}
+
+	res = Runner{Input: "a\nb\n"}.Run("wc -l | tr -d ' '")
+	if res.Stdout != "2\n" {
+		t.Errorf("Runner did not pass the input: %q", res.Stdout)
+	}
+	res = Runner{Stdin: strings.NewReader("from reader\n"), Input: "ignored"}.Run("cat")
+	if res.Stdout != "from reader\n" {
+		t.Errorf("Runner did not pass the stdin: %q", res.Stdout)
+	}
+}
+
+func TestRunnerShellOptions(t *testing.T) {
+	if res := (Runner{}).Run("false | true"); !res.Success() {
+		t.Errorf("a failed pipeline stage failed the command without pipefail: %+v", res)
+	}
+	if res := (Runner{Pipefail: true}).Run("false | true"); res.Success() {
+		t.Errorf("a failed pipeline stage did not fail the command with pipefail")
+	}
+
+	if res := (Runner{NoUnset: true}).Run("echo $GOCLI_TEST_UNSET"); res.Success() {
+		t.Errorf("an unset variable did not fail the command with NoUnset")
+	}
+
+	res := Runner{Trace: true}.Run("echo traced")
+	if !strings.Contains(res.Stderr, "+ echo traced") {
+		t.Errorf("Runner did not trace the command: %q", res.Stderr)
+	}
+
+	res = Runner{Shell: ShellSh}.Run("echo $0")
+	if res.Stdout != "sh\n" {
+		t.Errorf("Runner did not run sh: %q", res.Stdout)
+	}
+}
+
+func TestRunnerNoShell(t *testing.T) {
+	res := Runner{Shell: ShellNone}.Run(`printf "%s|%s\n" 'a b' $HOME`)
+	if res.Stdout != "a b|$HOME\n" {
+		t.Errorf("Runner without a shell returned %q", res.Stdout)
+	}
+
+	res = Runner{Shell: ShellNone}.Run("")
+	if res.Err == nil || res.Success() {
+		t.Errorf("Runner without a shell ran an empty command")
+	}
+}
+
+func mustEvalSymlinks(t *testing.T, path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolved
+}
+
+func TestBashOutput(t *testing.T) {
+	// lines longer than the buffer of a bufio.Reader are not split
+	res := Bash("head -c 100000 /dev/zero | tr '\\0' x; echo")
+	if res.Stdout != strings.Repeat("x", 100000)+"\n" {
+		t.Errorf("Bash returned a long line of %d bytes", len(res.Stdout))
+	}
+
+	// the output is returned as it is
+	res = Bash(`printf 'a\r\nno newline'; printf partial >&2`)
+	if res.Stdout != "a\r\nno newline" || res.Stderr != "partial" {
+		t.Errorf("Bash returned %q and %q", res.Stdout, res.Stderr)
+	}
+}
+
+func TestBashStreamLabel(t *testing.T) {
+	var res BashResult
+	out := captureStdout(t, func() {
+		res = BashStreamLabel(`echo one; printf 'two\r\nthree'`, true, false, "[x] ")
+	})
+	if out != "[x] one"+Sep()+"[x] two"+Sep()+"[x] three"+Sep() {
+		t.Errorf("BashStreamLabel streamed %q", out)
+	}
+	if res.Stdout != "one\ntwo\r\nthree" {
+		t.Errorf("BashStreamLabel returned %q", res.Stdout)
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	lines := []string{}
+	w := &lineWriter{emit: func(line string) { lines = append(lines, line) }}
+	for _, chunk := range []string{"fi", "rst\nsec", "ond\r", "\n\nla", "st"} {
+		w.Write([]byte(chunk))
+	}
+	w.flush()
+
+	expected := []string{"first", "second", "", "last"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("lineWriter emitted %q", lines)
+	}
+}
+
+func BenchmarkLineWriter(b *testing.B) {
+	chunk := []byte(strings.Repeat(strings.Repeat("x", 99)+"\n", 320))
+	w := &lineWriter{emit: func(line string) {}}
+	b.SetBytes(int64(len(chunk)))
+	for i := 0; i < b.N; i++ {
+		w.Write(chunk)
+	}
+}
+
+func TestBashLargeOutput(t *testing.T) {
+	// both streams are read at the same time, so a command which fills the
+	// pipe of one while writing the other does not block
+	res := Bash("for i in $(seq 1 20000); do echo out$i; echo err$i >&2; done")
+	if !res.Success() {
+		t.Fatalf("Bash returned %+v", res.Err)
+	}
+	outLines := strings.Split(strings.TrimSuffix(res.Stdout, "\n"), "\n")
+	errLines := strings.Split(strings.TrimSuffix(res.Stderr, "\n"), "\n")
+	if len(outLines) != 20000 || len(errLines) != 20000 || outLines[19999] != "out20000" || errLines[19999] != "err20000" {
+		t.Errorf("Bash returned %d lines of stdout and %d lines of stderr", len(outLines), len(errLines))
+	}
+
+	res = Bash("head -c 5000000 /dev/zero")
+	if len(res.Stdout) != 5000000 {
+		t.Errorf("Bash returned %d bytes of 5000000", len(res.Stdout))
+	}
+}
+
+func TestBashFastExit(t *testing.T) {
+	// the output of commands which exit right away is not lost
+	for i := 0; i < 50; i++ {
+		var res BashResult
+		var out string
+		errOut := captureStderr(t, func() {
+			out = captureStdout(t, func() {
+				res = BashStream("printf x; printf y >&2", true, true)
+			})
+		})
+		if res.Stdout != "x" || res.Stderr != "y" || !res.Success() {
+			t.Fatalf("Bash returned %+v", res)
+		}
+		if out != "x"+Sep() || errOut != "y"+Sep() {
+			t.Fatalf("BashStream streamed %q and %q", out, errOut)
+		}
+	}
+}
+
+func TestBashBackground(t *testing.T) {
+	// the background process keeps the pipes open, but Bash does not wait
+	// for it
+	start := time.Now()
+	res := Bash("sleep 3 & echo hi")
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Bash waited %s for the background process", elapsed)
+	}
+	if res.Stdout != "hi\n" || !res.Success() {
+		t.Errorf("Bash returned %+v", res)
+	}
+
+	// output which keeps coming after the command exited is still read
+	res = Bash("(for i in 1 2 3; do sleep 0.05; echo $i; done) &")
+	if res.Stdout != "1\n2\n3\n" {
+		t.Errorf("Bash returned %q", res.Stdout)
+	}
+}
+
+func TestRunnerSlowOnLine(t *testing.T) {
+	// the output which is still in the pipes when the command exits is read,
+	// however long OnLine takes
+	lines := []string{}
+	r := Runner{OnLine: func(line OutputLine) {
+		time.Sleep(150 * time.Millisecond)
+		lines = append(lines, line.Text)
+	}}
+	res := r.Run("for line in a b c d; do echo $line; sleep 0.01; done")
+	if res.Stdout != "a\nb\nc\nd\n" || res.Err != nil {
+		t.Errorf("Run returned %+v", res)
+	}
+	if strings.Join(lines, " ") != "a b c d" {
+		t.Errorf("OnLine received %q", lines)
+	}
+}
+
+func TestBashFailedStart(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	for _, r := range []Runner{{Dir: missing}, {Shell: missing}} {
+		res := r.Run("echo unreachable")
+		if res.Err == nil || res.Success() || res.Pid != 0 || res.ExitCode != -1 || res.Stdout != "" {
+			t.Errorf("Runner %+v returned %+v", r, res)
+		}
+	}
+}
+
+func TestRunnerWriters(t *testing.T) {
+	stdout, log := &strings.Builder{}, &bytes.Buffer{}
+	res := Runner{Stdout: io.MultiWriter(stdout, log), Stderr: log}.Run("echo out; sleep 0.05; echo err >&2")
+	if stdout.String() != "out\n" || log.String() != "out\nerr\n" {
+		t.Errorf("Runner wrote %q and %q", stdout.String(), log.String())
+	}
+	if res.Stdout != "out\n" || res.Stderr != "err\n" {
+		t.Errorf("Runner did not capture the output with writers: %+v", res)
+	}
+
+	res = Runner{Stdout: failingWriter{}}.Run("echo out")
+	if res.Err == nil || !strings.Contains(res.Err.Error(), "write failed") {
+		t.Errorf("Runner returned the error %v for a failing writer", res.Err)
+	}
+}
+
+func TestRunnerOnLine(t *testing.T) {
+	lines := []OutputLine{}
+	start := time.Now()
+	res := Runner{
+		OnLine:   func(line OutputLine) { lines = append(lines, line) },
+		Combined: true,
+	}.Run("echo a; sleep 0.05; echo b >&2; sleep 0.05; printf c")
+
+	got := []string{}
+	for _, line := range lines {
+		got = append(got, line.Stream.String()+":"+line.Text)
+		if line.Time.Before(start) || line.Time.After(time.Now()) {
+			t.Errorf("the line %q has the time %s", line.Text, line.Time)
+		}
+	}
+	if strings.Join(got, " ") != "stdout:a stderr:b stdout:c" {
+		t.Errorf("OnLine was called with %q", got)
+	}
+	if !lines[0].Time.Before(lines[2].Time) {
+		t.Errorf("the lines are not in order of time")
+	}
+
+	if res.Combined != "a\nb\nc" || res.Stdout != "a\nc" || res.Stderr != "b\n" {
+		t.Errorf("Runner returned %+v", res)
+	}
+	if Bash("echo a").Combined != "" {
+		t.Errorf("the combined output was captured without Combined")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestRunnerStreamLabels(t *testing.T) {
+	r := Runner{
+		StreamStdout:    true,
+		StreamStderr:    true,
+		Label:           "[all] ",
+		StderrLabel:     "[err] ",
+		StdoutColor:     Cyan,
+		StderrColor:     Red,
+		TimestampFormat: "15:04:05",
+	}
+	var out string
+	errOut := captureStderr(t, func() {
+		out = captureStdout(t, func() {
+			r.Run("echo out; echo err >&2")
+		})
+	})
+
+	// the output is not a terminal, so it has no colors
+	if !regexp.MustCompile(`^\d\d:\d\d:\d\d \[all\] out` + Sep() + `$`).MatchString(out) {
+		t.Errorf("Runner streamed %q to stdout", out)
+	}
+	if !regexp.MustCompile(`^\d\d:\d\d:\d\d \[err\] err` + Sep() + `$`).MatchString(errOut) {
+		t.Errorf("Runner streamed %q to stderr", errOut)
+	}
+}
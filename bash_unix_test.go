@@ -0,0 +1,67 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package gocli
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Returns the process group of a command which Runner.run started
+func processGroup(t *testing.T, res BashResult) int {
+	pgid, err := strconv.Atoi(strings.TrimSpace(res.Stdout))
+	if err != nil {
+		t.Fatalf("ps printed %q: %v", res.Stdout, res.Err)
+	}
+	return pgid
+}
+
+func TestBashProcessGroup(t *testing.T) {
+	const cmd = "ps -o pgid= -p $$"
+
+	// Ctrl-C in the terminal reaches the commands which stay in our group
+	if pgid := processGroup(t, Bash(cmd)); pgid != syscall.Getpgrp() {
+		t.Errorf("Bash ran in the process group %d rather than %d", pgid, syscall.Getpgrp())
+	}
+
+	// commands which a context can kill run in their own group
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	res := BashContext(ctx, cmd)
+	if pgid := processGroup(t, res); pgid != res.Pid {
+		t.Errorf("BashContext ran in the process group %d rather than %d", pgid, res.Pid)
+	}
+}
+
+// Returns the CPU time which the test process used so far
+func cpuTime(b *testing.B) time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		b.Fatal(err)
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// CPU time which waiting for a command costs the calling process. It should
+// be far below the 100ms the command takes
+func BenchmarkBashIdle(b *testing.B) {
+	start := cpuTime(b)
+	for i := 0; i < b.N; i++ {
+		Bash("sleep 0.1")
+	}
+	b.ReportMetric(float64(cpuTime(b)-start)/float64(b.N)/1e6, "cpu-ms/op")
+}
+
+// CPU time which reading a large output costs the calling process
+func BenchmarkBashOutput(b *testing.B) {
+	start := cpuTime(b)
+	for i := 0; i < b.N; i++ {
+		Bash("head -c 10000000 /dev/zero | tr '\\0' 'x' | fold -w 100")
+	}
+	b.ReportMetric(float64(cpuTime(b)-start)/float64(b.N)/1e6, "cpu-ms/op")
+}
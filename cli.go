@@ -1,8 +1,12 @@
 package gocli
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"text/template"
 )
 
 func NewCli(entrypoint *Command) Cli {
@@ -17,18 +21,79 @@ type Cli struct {
 
 	// Maps commands to their children
 	childrenMap map[*Command][]*Command
+
+	// Wraps every Behavior invocation
+	middleware []Middleware
+
+	// Renders the help strings, if set
+	helpTemplate *template.Template
+
+	// List the commands alphabetically in the help strings
+	sortCommands bool
+
+	// Add "--version" and the version command
+	version bool
+
+	// Run executables named after the commands, see EnablePlugins
+	plugins    bool
+	pluginDirs []string
+
+	// Options of the shell command, if it is enabled
+	shell *ShellOptions
+
+	// Set while the shell runs
+	inShell bool
+
+	// Context of the command which the shell runs, canceled on Ctrl-C
+	ctx context.Context
 }
 
 func (cli *Cli) Exec() {
 	args := os.Args[1:]
 
+	cli.addBuiltins()
+
 	// Check that the CLI tree structure is valid
 	g := cli2Graph(cli)
 	if !g.isTree() {
 		panic(fmt.Errorf("Cli command tree has invalid structure."))
 	}
+
+	// Called by the completion scripts
+	if len(args) > 0 && args[0] == completeCommandName {
+		if err := cli.writeCompletions(os.Stdout, args[1:]); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
 	// Run the root
-	cli.Entrypoint.RunUtil(args, cli.childrenMap, []string{})
+	if err := cli.run(args); err != nil {
+		var code exitCode
+		if errors.As(err, &code) {
+			os.Exit(int(code))
+		}
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// An error which makes Exec exit with the code without printing anything,
+// e.g. when a plugin failed and printed its own errors
+type exitCode int
+
+func (code exitCode) Error() string {
+	return fmt.Sprintf("Exit status %d.", int(code))
+}
+
+// Route the args through the command tree and run the matching command
+func (cli *Cli) run(args []string) error {
+	return cli.Entrypoint.runUtil(cli, args, []string{}, []*Command{})
+}
+
+// Add middleware which wraps the Behavior of every command in the CLI.
+// Middleware runs in the order it is added, the first being the outermost
+func (cli *Cli) Use(middleware ...Middleware) {
+	cli.middleware = append(cli.middleware, middleware...)
 }
 
 func (cli *Cli) AddChild(parent *Command, child *Command) error {
@@ -41,12 +106,144 @@ func (cli *Cli) AddChild(parent *Command, child *Command) error {
 	return nil
 }
 
+// Add the built-in commands to the entrypoint, unless the entrypoint already
+// has children with the same names. "help" and "completion" are left out if
+// the entrypoint takes an argument, which they would take the place of, e.g.
+// "cat help" reads the file "help". The enabled built-ins are always added
+func (cli *Cli) addBuiltins() {
+	builtins := []*Command{}
+	if cli.Entrypoint.Argument.Name == "" {
+		builtins = append(builtins, cli.completionCommand(), cli.helpCommand())
+	}
+	if cli.version {
+		builtins = append(builtins, cli.versionCommand())
+	}
+	if cli.shell != nil {
+		builtins = append(builtins, cli.shellCommand())
+	}
+	for _, builtin := range builtins {
+		if !cli.HasChild(cli.Entrypoint, builtin) {
+			cli.AddChild(cli.Entrypoint, builtin)
+		}
+	}
+}
+
+// Returns a copy of the CLI with the built-in commands, e.g. for generating
+// the documentation without changing the tree of the CLI
+func (cli *Cli) withBuiltins() *Cli {
+	copied := *cli
+	copied.childrenMap = make(map[*Command][]*Command, len(cli.childrenMap))
+	for parent, children := range cli.childrenMap {
+		copied.childrenMap[parent] = append([]*Command{}, children...)
+	}
+	copied.addBuiltins()
+	return &copied
+}
+
+// Visit every command in the tree, parents before children. "parents" are the
+// names of the commands above cmd, starting with the entrypoint
+func (cli *Cli) walk(visit func(cmd *Command, parents []string)) {
+	var walk func(cmd *Command, parents []string)
+	walk = func(cmd *Command, parents []string) {
+		visit(cmd, parents)
+		for _, child := range cli.childrenMap[cmd] {
+			walk(child, append(append([]string{}, parents...), cmd.Name))
+		}
+	}
+	walk(cli.Entrypoint, []string{})
+}
+
+// Visit every command which is listed in the help strings, parents before
+// children. Hidden commands and their descendants are skipped
+func (cli *Cli) walkListed(visit func(cmd *Command, parents []string)) {
+	var walk func(cmd *Command, parents []string)
+	walk = func(cmd *Command, parents []string) {
+		visit(cmd, parents)
+		for _, child := range cli.listedChildren(cmd) {
+			walk(child, append(append([]string{}, parents...), cmd.Name))
+		}
+	}
+	walk(cli.Entrypoint, []string{})
+}
+
+// Find the command which the words refer to, in the same way as RunUtil.
+// Returns the command, the names of the commands above it and the words
+// which follow it
+func (cli *Cli) route(words []string) (cmd *Command, parents []string, rest []string) {
+	cmd = cli.Entrypoint
+	parents = []string{}
+	rest = words
+	for len(rest) > 0 {
+		var next *Command
+		for _, child := range cli.childrenMap[cmd] {
+			if child.hasName(rest[0]) {
+				next = child
+			}
+		}
+		if next == nil {
+			break
+		}
+		parents = append(parents, cmd.Name)
+		cmd = next
+		rest = rest[1:]
+	}
+	return
+}
+
+// Returns the options of a command, including the default options, and
+// "--version" for the entrypoint if it is enabled
+func (cli *Cli) options(cmd *Command) []Option {
+	options := commandOptions(cmd)
+	if cli.version && cmd == cli.Entrypoint {
+		own := []Option{}
+		if cmd.Options != nil {
+			own = *cmd.Options
+		}
+		if def := unclaimed(VersionOption, own); def.Name() != "" {
+			options = append(options, def)
+		}
+	}
+	return options
+}
+
+// Build the context of a command, without its args. "parents" are the names
+// of the commands above it
+func (cli *Cli) context(cmd *Command, parents []string) Context {
+	ctx := cli.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return Context{
+		Referrer: strings.Join(append(append([]string{}, parents...), cmd.Name), " "),
+		Command:  cmd,
+		Options:  cli.options(cmd),
+		Children: cli.childrenMap[cmd],
+		Ctx:      ctx,
+		cli:      cli,
+	}
+}
+
+// Returns the names of the commands from the entrypoint down to cmd, or nil
+// if cmd is not in the tree
+func (cli *Cli) commandPath(cmd *Command) (path []string) {
+	cli.walk(func(c *Command, parents []string) {
+		if c == cmd && path == nil {
+			path = append(parents, c.Name)
+		}
+	})
+	return
+}
+
+// Returns true if a child of parent has the name or one of the aliases of
+// child (as its name or alias)
 func (cli *Cli) HasChild(parent *Command, child *Command) bool {
 	children := cli.childrenMap[parent]
 
 	for _, c := range children {
-		if c.Name == child.Name {
-			return true
+		for _, name := range child.names() {
+			if c.hasName(name) {
+				return true
+			}
 		}
 	}
 
@@ -90,12 +287,62 @@ func cli2Graph(cli *Cli) Graph {
 	return g
 }
 
-// undirected graph
+// Graph given by an adjacency matrix. isTree treats it as undirected, and
+// sortTopologically as directed: Adj[i][j] is an edge from i to j
 type Graph struct {
 	// adjacency matrix
 	Adj [][]bool
 }
 
+// Sort the vertices which can be reached from the roots so that every vertex
+// comes after the vertices it has edges to. The roots are followed in order,
+// the edges of a vertex in the order of the vertices they lead to. If a
+// cycle is found, the vertices on it are returned instead, starting and
+// ending with the same vertex, e.g. [0 2 0]
+func (g *Graph) sortTopologically(roots []int) (order []int, cycle []int) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(g.Adj))
+	path := []int{}
+
+	var visit func(v int) bool
+	visit = func(v int) bool {
+		switch state[v] {
+		case visited:
+			return true
+		case visiting:
+			for i, u := range path {
+				if u == v {
+					cycle = append(append([]int{}, path[i:]...), v)
+				}
+			}
+			return false
+		}
+
+		state[v] = visiting
+		path = append(path, v)
+		for u, edge := range g.Adj[v] {
+			if edge && !visit(u) {
+				return false
+			}
+		}
+		path = path[:len(path)-1]
+		state[v] = visited
+		order = append(order, v)
+		return true
+	}
+
+	for _, root := range roots {
+		if !visit(root) {
+			return nil, cycle
+		}
+	}
+	return order, nil
+}
+
 func (g *Graph) isCyclic(v int, visited map[int]bool, parent int) bool {
 	if len(g.Adj) == 0 {
 		return false
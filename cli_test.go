@@ -3,6 +3,7 @@ package gocli
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -169,3 +170,27 @@ func TestIsCyclic(t *testing.T) {
 		t.Errorf("isCyclic returned false for three element identity cyclic")
 	}
 }
+
+func TestBuiltinsRootArgument(t *testing.T) {
+	// the built-ins do not take the place of the root's argument
+	file := ""
+	root := Command{
+		Name:     "cat",
+		Argument: Argument{Name: "file"},
+		Behavior: func(ctx Context) { file = ctx.Args["file"].(string) },
+	}
+	cli := NewCli(&root)
+	cli.addBuiltins()
+
+	if err := cli.run([]string{"help"}); err != nil || file != "help" {
+		t.Errorf("cli.run(\"help\") read %q, err: %v", file, err)
+	}
+	if len(cli.childrenMap[&root]) != 0 {
+		t.Errorf("addBuiltins added %d commands to the root", len(cli.childrenMap[&root]))
+	}
+
+	help := captureStdout(t, func() { cli.run([]string{"--help"}) })
+	if strings.Contains(help, "[COMMAND]") {
+		t.Errorf("the help string of the root lists commands:\n%s", help)
+	}
+}
@@ -1,6 +1,7 @@
 package gocli
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -11,6 +12,25 @@ type Argument struct {
 	Name        string
 	Required    bool
 	Description string
+
+	// Returns the candidates for the argument during shell completion
+	Complete CompletionFunc
+}
+
+// An example invocation of a command
+type Example struct {
+	// The command line, e.g. "example run -n 3 ~/projects"
+	Command string
+
+	// What the example does
+	Description string
+}
+
+// A free-form section of a command's documentation, e.g. "Environment" or
+// "Exit Codes"
+type Section struct {
+	Title string
+	Body  string
 }
 
 // CLI Command
@@ -18,6 +38,9 @@ type Command struct {
 	// Name of the command (as referenced in the CLI)
 	Name string
 
+	// Other names which the command can be run with, e.g. "rm" for "remove"
+	Aliases []string
+
 	// Description that is shown when "--help" is present
 	LongDesc string
 
@@ -31,10 +54,72 @@ type Command struct {
 	// Argument
 	Argument Argument
 
+	// Examples which are shown in the help string and the documentation.
+	// Cli.ValidateExamples checks that they parse
+	Examples []Example
+
+	// Full names of related commands, e.g. "example run"
+	SeeAlso []string
+
+	// Additional sections of the help string and the documentation
+	Sections []Section
+
+	// A heading under which the command is listed in its parent's help
+	// string, e.g. "Cluster Management". Commands without a group are listed
+	// under "Commands"
+	Group string
+
+	// Position of the command in its parent's help string. Commands are
+	// sorted by Order, then in the order they were added (or alphabetically,
+	// see Cli.SetSortCommands)
+	Order int
+
 	// Behavior of the command
 	Behavior func(ctx Context)
+
+	// A text/template which renders the help string of the command instead
+	// of the CLI's help template. See HelpData for the data passed to it
+	HelpTemplate string
+
+	// Runs before PreRun on this command and on every descendant command
+	PersistentPreRun Hook
+
+	// Runs before Behavior when this command is the one being executed
+	PreRun Hook
+
+	// Runs after Behavior when this command is the one being executed
+	PostRun Hook
+
+	// Runs after PostRun on this command and on every descendant command
+	PersistentPostRun Hook
+
+	// Leave the command out of its parent's help string, the documentation
+	// and the completions. It can still be run
+	Hidden bool
+
+	// If set, running the command (or one of its descendants) prints a
+	// warning followed by this message, which should name the replacement,
+	// e.g. "Use 'example deploy' instead."
+	Deprecated string
+
+	// The command (and its descendants) can only be run if experimental
+	// features are enabled, see ExperimentalEnv
+	Experimental bool
+
+	// Behavior of the built-in commands. It replaces Behavior and receives
+	// the args unparsed, in Context.StrArgs
+	action func(ctx Context) error
 }
 
+// A function which runs before or after a command's Behavior. Returning an
+// error aborts the rest of the execution.
+type Hook func(ctx Context) error
+
+// A function which wraps every Behavior invocation of a Cli. "next" runs the
+// rest of the chain (and eventually the Behavior); a middleware may skip it
+// and return an error to abort the execution.
+type Middleware func(ctx Context, next func(ctx Context) error) error
+
 type Context struct {
 	// Parent's name.
 	// For example, if "install" is the child of "run" which is the child of "root",
@@ -58,158 +143,200 @@ type Context struct {
 	// respective types. An argument default to the value of <nil> if they
 	// are not included in the cli command
 	Args map[string]interface{}
+
+	// Done when the command should stop, i.e. on Ctrl-C in the interactive
+	// shell. Pass it to BashContext or RunJobs, so that the commands they
+	// start are killed then. Never done outside the shell
+	Ctx context.Context
+
+	// The CLI which is running the command
+	cli *Cli
 }
 
 func (c *Command) Run(args []string, parents []string, children []*Command) {
+	cli := &Cli{
+		Entrypoint:  c,
+		childrenMap: map[*Command][]*Command{c: children},
+	}
+	if err := c.run(cli, args, parents, []*Command{}); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// Run the command with the hooks of its ancestors and the middleware of the cli.
+//
+// "ancestors" are the commands from the root down to (but not including) c
+func (c *Command) run(cli *Cli, args []string, parents []string, ancestors []*Command) error {
+
+	context := cli.context(c, parents)
+	context.StrArgs = args
 
-	if c.Options == nil {
-		c.Options = &[]Option{}
+	if wantsHelp(c, args) {
+		fmt.Println(context.HelpStr())
+		return nil
 	}
-	temp := *c.Options
-	(*c.Options) = append((*c.Options), DefaultOptions...)
 
-	// build the context
-	context := Context{
-		Referrer: strings.Join(append(parents, c.Name), " "),
-		Command:  c,
-		Options:  *c.Options,
-		StrArgs:  args,
-		Children: children,
+	if c == cli.Entrypoint && cli.version && hasDefaultFlag(c, VersionOption, context.Options, args) {
+		fmt.Println(cli.versionInfo().Text(c.Name))
+		return nil
 	}
 
-	for _, arg := range args {
-		if arg == "--help" {
-			fmt.Println(context.HelpStr())
-			return
+	// hooks run from the root down to the command being executed
+	lineage := append(append([]*Command{}, ancestors...), c)
+
+	// the commands are checked before the args are parsed, which an action
+	// does itself
+	experimental := experimentalEnabled(nil) || hasDefaultFlag(c, ExperimentalOption, context.Options, args)
+	// the parents may start above the first ancestor, e.g. with Command.Run
+	offset := len(parents) - len(ancestors)
+	for i, cmd := range lineage {
+		name := strings.Join(append(append([]string{}, parents[:offset+i]...), cmd.Name), " ")
+		if cmd.Experimental && !experimental {
+			return fmt.Errorf("Command '%s' is experimental. Set %s=1 or pass --%s to enable it.", name, ExperimentalEnv, ExperimentalOption.Long)
 		}
+		if cmd.Deprecated != "" {
+			warn(fmt.Sprintf("Command '%s' is deprecated. %s", name, cmd.Deprecated))
+		}
+	}
+	for _, msg := range deprecationWarnings(context.Options, args) {
+		warn(msg)
 	}
 
-	if c.Behavior == nil {
-		fmt.Printf("Behavior method not configured for command '%s'", context.Referrer)
-		return
+	behavior := c.action
+	if behavior == nil {
+		if c.Behavior == nil {
+			fmt.Printf("Behavior method not configured for command '%s'", context.Referrer)
+			return nil
+		}
+
+		if err := populateArgs(&context); err != nil {
+			return err
+		}
+		behavior = func(ctx Context) error {
+			c.Behavior(ctx)
+			return nil
+		}
 	}
 
-	populateArgs(&context)
+	for _, cmd := range lineage {
+		if cmd.PersistentPreRun != nil {
+			if err := cmd.PersistentPreRun(context); err != nil {
+				return err
+			}
+		}
+	}
+	if c.PreRun != nil {
+		if err := c.PreRun(context); err != nil {
+			return err
+		}
+	}
 
 	// run the behavior
-	c.Behavior(context)
+	if err := chain(cli.middleware, behavior)(context); err != nil {
+		return err
+	}
 
-	c.Options = &temp
+	if c.PostRun != nil {
+		if err := c.PostRun(context); err != nil {
+			return err
+		}
+	}
+	for _, cmd := range lineage {
+		if cmd.PersistentPostRun != nil {
+			if err := cmd.PersistentPostRun(context); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Wrap a behavior in the middleware. The first middleware is the outermost
+func chain(middleware []Middleware, behavior func(ctx Context) error) func(ctx Context) error {
+	next := behavior
+	for i := len(middleware) - 1; i >= 0; i-- {
+		m, inner := middleware[i], next
+		next = func(ctx Context) error {
+			return m(ctx, inner)
+		}
+	}
+	return next
 }
 
 func (c *Command) RunUtil(args []string, childrenMap map[*Command][]*Command, parents []string) {
+	cli := &Cli{
+		Entrypoint:  c,
+		childrenMap: childrenMap,
+	}
+	if err := c.runUtil(cli, args, parents, []*Command{}); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// Route the args to the matching child command and run it
+func (c *Command) runUtil(cli *Cli, args []string, parents []string, ancestors []*Command) error {
 	if len(args) == 0 || string(args[0][0]) == "-" {
-		c.Run(args, parents, childrenMap[c])
+		return c.run(cli, args, parents, ancestors)
 	} else {
 		subCmd := &Command{}
 
 		// check if the args match a child command
-		for _, child := range childrenMap[c] {
-			if child.Name == args[0] {
+		for _, child := range cli.childrenMap[c] {
+			if child.hasName(args[0]) {
 				subCmd = child
 			}
 		}
 		if subCmd.Name == "" {
-			c.Run(args, parents, childrenMap[c])
+			// the args may name a plugin, unless they are the command's argument
+			if cli.plugins && c.Argument.Name == "" {
+				path := append(append([]string{}, parents...), c.Name)
+				if plugin, rest, ok := cli.lookupPlugin(path, args); ok {
+					return cli.runPlugin(plugin, path, rest)
+				}
+			}
+			return c.run(cli, args, parents, ancestors)
 		} else {
-			subCmd.RunUtil(args[1:], childrenMap, append(parents, c.Name))
+			return subCmd.runUtil(cli, args[1:], append(parents, c.Name), append(ancestors, c))
 		}
 	}
 }
 
-func max(x int, y int) int {
-	if x > y {
-		return x
-	} else {
-		return y
-	}
-}
-
-func paddedName(name string, width int) (p string) {
-	p += name
-	for i := 0; i < width-len(name); i++ {
-		p += " "
-	}
-	return p
+// Returns the name and the aliases of the command
+func (c *Command) names() []string {
+	return append([]string{c.Name}, c.Aliases...)
 }
 
-// Returns the help string for a command
-func (c *Context) HelpStr() string {
-	padding := 5
-	txt := ""
-	name := c.Referrer
-
-	txt += fmt.Sprintf("Usage: %s", name)
-	if len(c.Children) > 0 {
-		txt += " [COMMAND]"
-	}
-
-	if len(c.Options) > 0 {
-		txt += fmt.Sprintf(" [OPTIONS]")
-	}
-
-	if c.Command.Argument.Name != "" {
-		txt += fmt.Sprintf(" %s", c.Command.Argument.Name)
-	}
-
-	txt += Sep()
-
-	if c.Command.LongDesc != "" {
-		txt += c.Command.LongDesc
-		txt += Sep()
-	}
-	txt += Sep()
-
-	if len(c.Children) > 0 {
-		txt += "Commands:" + Sep()
-
-		maxWidth := 0
-		for _, child := range c.Children {
-			maxWidth = max(len(child.Name), maxWidth)
-		}
-		width := maxWidth + padding
-		for _, child := range c.Children {
-			txt += "  " + paddedName(child.Name, width) + child.ShortDesc + Sep()
+// Returns true if name is the name or an alias of the command
+func (c *Command) hasName(name string) bool {
+	for _, n := range c.names() {
+		if n == name {
+			return true
 		}
-		txt += Sep()
-	}
-	if options := c.Options; len(options) > 0 {
-		txt += "Options:" + Sep()
-
-		maxWidth := 0
-		for _, option := range options {
-			maxWidth = max(len(option.Name()), maxWidth)
-		}
-		width := maxWidth + padding
-		for _, option := range options {
-			required := "Optional"
-			if option.Required {
-				required = "Required"
-			}
-			txt += "  " + paddedName(option.Name(), width) + fmt.Sprintf("[%s, Type: %s] ", required, option.Type) + option.Description + Sep()
-		}
-		txt += Sep()
 	}
+	return false
+}
 
-	if arg := c.Command.Argument; arg.Name != "" {
-		required := "Optional"
-		if c.Command.Argument.Required {
-			required = "Required"
+// Returns the commands which are not hidden
+func visibleCommands(commands []*Command) []*Command {
+	visible := []*Command{}
+	for _, cmd := range commands {
+		if !cmd.Hidden {
+			visible = append(visible, cmd)
 		}
-		txt += fmt.Sprintf("Argument: '%s' (%s)", arg.Name, required) + Sep()
-		txt += arg.Description
 	}
-
-	return txt
+	return visible
 }
 
 // Populate an interface with argument values
-func populateArgs(c *Context) {
-	args, err := ParseArgs(*c.Command.Options, c.Command.Argument, c.StrArgs)
+func populateArgs(c *Context) error {
+	args, err := ParseArgs(c.Options, c.Command.Argument, c.StrArgs)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return err
 	}
 
 	c.Args = args
+	return nil
 }
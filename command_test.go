@@ -0,0 +1,290 @@
+package gocli
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHookOrder(t *testing.T) {
+	calls := []string{}
+	record := func(s string) Hook {
+		return func(ctx Context) error {
+			calls = append(calls, s)
+			return nil
+		}
+	}
+
+	root := Command{
+		Name:              "root",
+		PersistentPreRun:  record("root persistent pre"),
+		PreRun:            record("root pre"),
+		PostRun:           record("root post"),
+		PersistentPostRun: record("root persistent post"),
+	}
+	child := Command{
+		Name:              "child",
+		PersistentPreRun:  record("child persistent pre"),
+		PreRun:            record("child pre"),
+		PostRun:           record("child post"),
+		PersistentPostRun: record("child persistent post"),
+		Behavior: func(ctx Context) {
+			calls = append(calls, "behavior")
+		},
+	}
+
+	cli := NewCli(&root)
+	cli.AddChild(&root, &child)
+	cli.Use(func(ctx Context, next func(ctx Context) error) error {
+		calls = append(calls, "outer before")
+		err := next(ctx)
+		calls = append(calls, "outer after")
+		return err
+	}, func(ctx Context, next func(ctx Context) error) error {
+		calls = append(calls, "inner")
+		return next(ctx)
+	})
+
+	if err := cli.run([]string{"child"}); err != nil {
+		t.Errorf("cli.run returned an error: %s", err)
+	}
+
+	expected := []string{
+		"root persistent pre",
+		"child persistent pre",
+		"child pre",
+		"outer before",
+		"inner",
+		"behavior",
+		"outer after",
+		"child post",
+		"root persistent post",
+		"child persistent post",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("hooks ran in the wrong order: %v", calls)
+	}
+}
+
+func TestHookAbort(t *testing.T) {
+	ran := false
+	root := Command{
+		Name: "root",
+		PreRun: func(ctx Context) error {
+			return fmt.Errorf("not logged in")
+		},
+		Behavior: func(ctx Context) {
+			ran = true
+		},
+	}
+
+	cli := NewCli(&root)
+	if err := cli.run([]string{}); err == nil || err.Error() != "not logged in" {
+		t.Errorf("cli.run did not return the PreRun error: %v", err)
+	}
+	if ran {
+		t.Errorf("Behavior ran after PreRun returned an error")
+	}
+
+	// middleware can abort
+	root.PreRun = nil
+	cli.Use(func(ctx Context, next func(ctx Context) error) error {
+		return fmt.Errorf("aborted")
+	})
+	if err := cli.run([]string{}); err == nil || err.Error() != "aborted" {
+		t.Errorf("cli.run did not return the middleware error: %v", err)
+	}
+	if ran {
+		t.Errorf("Behavior ran after middleware returned an error")
+	}
+}
+
+func TestHiddenCommand(t *testing.T) {
+	ran := false
+	root := Command{Name: "root"}
+	secret := Command{
+		Name:     "secret",
+		Hidden:   true,
+		Behavior: func(ctx Context) { ran = true },
+		Options:  &[]Option{{Long: "force", Type: "bool", Hidden: true}},
+	}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &secret)
+	cli.addBuiltins()
+
+	if err := cli.run([]string{"secret", "--force"}); err != nil || !ran {
+		t.Errorf("hidden command did not run: %v", err)
+	}
+
+	ctx := cli.context(&root, []string{})
+	if help := ctx.HelpStr(); strings.Contains(help, "secret") {
+		t.Errorf("HelpStr listed a hidden command:\n%s", help)
+	}
+	if values := completeValues(cli, []string{"s"}); len(values) != 0 {
+		t.Errorf("complete listed a hidden command: %q", values)
+	}
+	if values := completeValues(cli, []string{"secret", "--"}); !reflect.DeepEqual(values, []string{"--help"}) {
+		t.Errorf("complete listed hidden options: %q", values)
+	}
+	if values := completeValues(cli, []string{"help", "s"}); len(values) != 0 {
+		t.Errorf("help completion listed a hidden command: %q", values)
+	}
+
+	ctx = cli.context(&secret, []string{"root"})
+	if help := ctx.HelpStr(); strings.Contains(help, "--force") || strings.Contains(help, "--experimental") {
+		t.Errorf("HelpStr listed a hidden option:\n%s", help)
+	}
+}
+
+func TestDeprecatedCommand(t *testing.T) {
+	ran := false
+	root := Command{Name: "root"}
+	old := Command{
+		Name:       "old",
+		Deprecated: "Use 'root new' instead.",
+		Behavior:   func(ctx Context) { ran = true },
+	}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &old)
+
+	var err error
+	out := captureStderr(t, func() { err = cli.run([]string{"old"}) })
+	if err != nil || !ran {
+		t.Errorf("deprecated command did not run: %v", err)
+	}
+	if !strings.Contains(out, "Warning: Command 'root old' is deprecated. Use 'root new' instead.") {
+		t.Errorf("deprecated command printed %q", out)
+	}
+}
+
+func TestDeprecatedOption(t *testing.T) {
+	root := Command{Name: "root"}
+	run := Command{
+		Name:     "run",
+		Options:  &[]Option{{Long: "old", Type: "string", Deprecated: "Use --new instead."}},
+		Behavior: func(ctx Context) {},
+	}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &run)
+
+	// printed once, although the args are parsed again by the command
+	out := captureStderr(t, func() { cli.run([]string{"run", "--old", "x"}) })
+	if strings.Count(out, "Warning: Option `--old` is deprecated. Use --new instead.") != 1 {
+		t.Errorf("deprecated option printed %q", out)
+	}
+
+	// completing a command line does not print anything
+	out = captureStderr(t, func() { cli.complete([]string{"run", "--old", "x", ""}) })
+	if out != "" {
+		t.Errorf("completing a deprecated option printed %q", out)
+	}
+}
+
+func TestExperimentalCommand(t *testing.T) {
+	t.Setenv(ExperimentalEnv, "")
+
+	ran := false
+	root := Command{Name: "root"}
+	beta := Command{Name: "beta", Experimental: true}
+	child := Command{Name: "child", Behavior: func(ctx Context) { ran = true }}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &beta)
+	cli.AddChild(&beta, &child)
+
+	// descendants of an experimental command are experimental
+	err := cli.run([]string{"beta", "child"})
+	if err == nil || err.Error() != "Command 'root beta' is experimental. Set GOCLI_EXPERIMENTAL=1 or pass --experimental to enable it." {
+		t.Errorf("experimental command did not return an error: %v", err)
+	}
+	if ran {
+		t.Errorf("experimental command ran without being enabled")
+	}
+
+	if err := cli.run([]string{"beta", "child", "--experimental"}); err != nil || !ran {
+		t.Errorf("experimental command did not run with --experimental: %v", err)
+	}
+
+	ran = false
+	t.Setenv(ExperimentalEnv, "1")
+	if err := cli.run([]string{"beta", "child"}); err != nil || !ran {
+		t.Errorf("experimental command did not run with %s=1: %v", ExperimentalEnv, err)
+	}
+}
+
+func TestExperimentalAction(t *testing.T) {
+	t.Setenv(ExperimentalEnv, "")
+
+	// commands with an action, like the task commands, parse their own args
+	ran := false
+	root := Command{Name: "root"}
+	beta := Command{Name: "beta", Experimental: true}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &beta)
+	set := &TaskSet{}
+	set.Add(&Task{Name: "build", Func: func(ctx context.Context) error {
+		ran = true
+		return nil
+	}})
+	if err := set.AddCommands(&cli, &beta); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cli.run([]string{"beta", "build"}); err == nil || ran {
+		t.Errorf("experimental task command ran without being enabled: %v", err)
+	}
+	captureStdout(t, func() {
+		if err := cli.run([]string{"beta", "build", "--experimental"}); err != nil || !ran {
+			t.Errorf("experimental task command did not run with --experimental: %v", err)
+		}
+	})
+}
+
+// Returns the values which cli.complete returns for args
+func completeValues(cli Cli, args []string) []string {
+	completions, _ := cli.complete(args)
+	return completionValues(completions)
+}
+
+func TestAliases(t *testing.T) {
+	setTerminalWidth(t, 200)
+
+	referrer := ""
+	root := Command{Name: "root"}
+	remove := Command{
+		Name:      "remove",
+		Aliases:   []string{"rm", "del"},
+		ShortDesc: "Remove it",
+		Behavior:  func(ctx Context) { referrer = ctx.Referrer },
+	}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &remove)
+	cli.addBuiltins()
+
+	for _, name := range []string{"remove", "rm", "del"} {
+		referrer = ""
+		if err := cli.run([]string{name}); err != nil || referrer != "root remove" {
+			t.Errorf("cli.run(%q) did not run the command: %v", name, err)
+		}
+	}
+
+	// aliases cannot collide with the names and aliases of siblings
+	for _, child := range []*Command{{Name: "rm"}, {Name: "delete", Aliases: []string{"del"}}, {Name: "x", Aliases: []string{"remove"}}} {
+		if err := cli.AddChild(&root, child); err == nil {
+			t.Errorf("AddChild accepted %s (aliases %v) next to remove", child.Name, child.Aliases)
+		}
+	}
+
+	ctx := cli.context(&remove, []string{"root"})
+	if help := strings.ReplaceAll(ctx.HelpStr(), Sep(), "\n"); !strings.Contains(help, "\nAliases:\n  rm, del\n\n") {
+		t.Errorf("HelpStr did not list the aliases:\n%s", help)
+	}
+
+	if values := completeValues(cli, []string{""}); !reflect.DeepEqual(values, []string{"remove", "rm", "del", "completion", "help"}) {
+		t.Errorf("complete did not list the aliases: %q", values)
+	}
+	if values := completeValues(cli, []string{"help", "d"}); !reflect.DeepEqual(values, []string{"del"}) {
+		t.Errorf("help completion did not list the aliases: %q", values)
+	}
+}
@@ -0,0 +1,500 @@
+package gocli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Shells for which a completion script can be generated
+var CompletionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// Name of the hidden command which the completion scripts call to get the
+// candidates for a partial command line
+const completeCommandName = "__complete"
+
+// A candidate for the word being completed
+type Completion struct {
+	Value string
+
+	// Shown next to the value by the shells which support it (zsh, fish
+	// and powershell)
+	Description string
+}
+
+// Tells the shell how to treat the candidates. Directives can be combined,
+// e.g. CompletionNoSpace | CompletionNoFiles
+type CompletionDirective int
+
+const (
+	// Complete file paths if there are no candidates
+	CompletionDefault CompletionDirective = 0
+
+	// Do not add a space after the completed word
+	CompletionNoSpace CompletionDirective = 1
+
+	// Do not complete file paths, even if there are no candidates
+	CompletionNoFiles CompletionDirective = 2
+
+	// Ignore the candidates and complete file paths only
+	CompletionFiles CompletionDirective = 4
+
+	// Ignore the candidates and complete directory paths only
+	CompletionDirs CompletionDirective = 8
+)
+
+// Returns the candidates for the word being completed ("toComplete"). The
+// Context is built from the rest of the command line, which may be
+// incomplete; missing options and arguments are absent from Context.Args
+type CompletionFunc func(ctx Context, toComplete string) ([]Completion, CompletionDirective)
+
+// Returns the CompletionFunc for Option.Complete
+func CompleteWith(f CompletionFunc) *CompletionFunc {
+	return &f
+}
+
+// Returns the flags of an option, e.g. ["-v", "--verbose"]
+func optionFlags(o Option) (flags []string) {
+	if o.Short != "" {
+		flags = append(flags, "-"+o.Short)
+	}
+	if o.Long != "" {
+		flags = append(flags, "--"+o.Long)
+	}
+	return
+}
+
+// Return the option which matches a flag such as "-v" or "--verbose"
+func matchFlag(flag string, options []Option) (Option, bool) {
+	if isLongFlag(flag) {
+		name, _ := parseLong(flag)
+		return matchLong(name, options)
+	}
+	if isShortFlag(flag) {
+		name, _ := parseShort(flag)
+		return matchShort(name, options)
+	}
+	return Option{}, false
+}
+
+// Returns the values of the candidates
+func completionValues(completions []Completion) []string {
+	values := []string{}
+	for _, c := range completions {
+		values = append(values, c.Value)
+	}
+	return values
+}
+
+// Keep the candidates which start with prefix
+func filterCompletions(completions []Completion, prefix string) []Completion {
+	filtered := []Completion{}
+	for _, c := range completions {
+		if strings.HasPrefix(c.Value, prefix) {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+// Compute the candidates for the last of "args", which is the (possibly
+// empty) word being completed. The other args are the words of the command
+// line after the name of the CLI
+func (cli *Cli) complete(args []string) ([]Completion, CompletionDirective) {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	words, toComplete := args[:len(args)-1], args[len(args)-1]
+
+	// older versions of PowerShell cannot pass an empty argument
+	if toComplete == `""` {
+		toComplete = ""
+	}
+
+	// route to the command being completed
+	cmd, parents, words := cli.route(words)
+
+	ctx := cli.context(cmd, parents)
+	ctx.StrArgs = words
+	ctx.Args = parsePartialArgs(ctx.Options, cmd.Argument, words)
+	options, children := ctx.Options, ctx.Children
+
+	// the value of an option, e.g. "--label=<value>"
+	if isLongFlag(toComplete) && strings.Contains(toComplete, "=") {
+		idx := strings.Index(toComplete, "=")
+		if opt, ok := matchFlag(toComplete[:idx], options); ok && opt.takesValue() {
+			prefix := toComplete[:idx+1]
+			completions, directive := completeValue(ctx, opt, toComplete[idx+1:])
+			for i := range completions {
+				completions[i].Value = prefix + completions[i].Value
+			}
+			return completions, directive
+		}
+		return []Completion{}, CompletionNoFiles
+	}
+
+	// the value of an option, e.g. "--label <value>"
+	if len(words) > 0 {
+		prev := words[len(words)-1]
+		if opt, ok := matchFlag(prev, options); ok && opt.takesValue() && !strings.Contains(prev, "=") {
+			return completeValue(ctx, opt, toComplete)
+		}
+	}
+
+	// an option
+	if strings.HasPrefix(toComplete, "-") {
+		completions := []Completion{}
+		for _, opt := range visibleOptions(options) {
+			for _, flag := range optionFlags(opt) {
+				completions = append(completions, Completion{Value: flag, Description: opt.Description})
+			}
+		}
+		return filterCompletions(completions, toComplete), CompletionNoFiles
+	}
+
+	// a child command, if no args follow the command, and/or the argument
+	completions := []Completion{}
+	directive := CompletionNoFiles
+	if len(words) == 0 {
+		for _, child := range visibleCommands(children) {
+			for _, name := range child.names() {
+				completions = append(completions, Completion{Value: name, Description: child.ShortDesc})
+			}
+		}
+	}
+	// the built-in commands may take several words, e.g. "help run"
+	if arg := cmd.Argument; arg.Name != "" && (ctx.Args[arg.Name] == nil || cmd.action != nil) {
+		directive = CompletionDefault
+		if arg.Complete != nil {
+			var argCompletions []Completion
+			argCompletions, directive = arg.Complete(ctx, toComplete)
+			completions = append(completions, argCompletions...)
+		}
+	}
+
+	return filterCompletions(completions, toComplete), directive
+}
+
+// Compute the candidates for the value of an option
+func completeValue(ctx Context, opt Option, toComplete string) ([]Completion, CompletionDirective) {
+	if opt.Complete != nil {
+		completions, directive := (*opt.Complete)(ctx, toComplete)
+		return filterCompletions(completions, toComplete), directive
+	}
+	if opt.Type == "string" {
+		return []Completion{}, CompletionDefault
+	}
+	return []Completion{}, CompletionNoFiles
+}
+
+// Write the candidates for a partial command line in the format expected by
+// the completion scripts: one "<value>\t<description>" line per candidate,
+// followed by ":<directive>"
+func (cli *Cli) writeCompletions(w io.Writer, args []string) error {
+	completions, directive := cli.complete(args)
+
+	b := &strings.Builder{}
+	for _, c := range completions {
+		// the values and descriptions must fit on one line
+		value := strings.NewReplacer("\n", " ", "\t", " ").Replace(c.Value)
+		desc := strings.NewReplacer("\r\n", " ", "\n", " ", "\t", " ").Replace(c.Description)
+		if desc != "" {
+			fmt.Fprintf(b, "%s\t%s\n", value, desc)
+		} else {
+			fmt.Fprintf(b, "%s\n", value)
+		}
+	}
+	fmt.Fprintf(b, ":%d\n", directive)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Returns a name for the CLI which is safe to use in shell function names
+func (cli *Cli) funcName() string {
+	return regexp.MustCompile("[^a-zA-Z0-9_]").ReplaceAllString(cli.Entrypoint.Name, "_")
+}
+
+// Write a completion script for "shell" to w
+func (cli *Cli) GenCompletion(shell string, w io.Writer) error {
+	switch shell {
+	case "bash":
+		return cli.GenBashCompletion(w)
+	case "zsh":
+		return cli.GenZshCompletion(w)
+	case "fish":
+		return cli.GenFishCompletion(w)
+	case "powershell":
+		return cli.GenPowerShellCompletion(w)
+	default:
+		return fmt.Errorf("Unsupported shell '%s'. Expected one of '%s'.", shell, strings.Join(CompletionShells, "', '"))
+	}
+}
+
+// Quote a string for bash and zsh
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Quote a string for fish
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// Quote a string for PowerShell
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Fill the placeholders of a completion script template
+func completionScript(w io.Writer, script string, cli *Cli, quote func(string) string) error {
+	r := strings.NewReplacer(
+		"{{NAME}}", cli.Entrypoint.Name,
+		"{{QUOTED_NAME}}", quote(cli.Entrypoint.Name),
+		"{{FUNC}}", cli.funcName(),
+		"{{COMPLETE}}", completeCommandName,
+		"{{NO_SPACE}}", fmt.Sprint(int(CompletionNoSpace)),
+		"{{NO_FILES}}", fmt.Sprint(int(CompletionNoFiles)),
+		"{{FILES}}", fmt.Sprint(int(CompletionFiles)),
+		"{{DIRS}}", fmt.Sprint(int(CompletionDirs)),
+	)
+	_, err := io.WriteString(w, r.Replace(script))
+	return err
+}
+
+// Write a bash completion script to w
+func (cli *Cli) GenBashCompletion(w io.Writer) error {
+	return completionScript(w, bashCompletion, cli, shQuote)
+}
+
+// Write a zsh completion script to w
+func (cli *Cli) GenZshCompletion(w io.Writer) error {
+	return completionScript(w, zshCompletion, cli, shQuote)
+}
+
+// Write a fish completion script to w
+func (cli *Cli) GenFishCompletion(w io.Writer) error {
+	return completionScript(w, fishCompletion, cli, fishQuote)
+}
+
+// Write a PowerShell completion script to w
+func (cli *Cli) GenPowerShellCompletion(w io.Writer) error {
+	return completionScript(w, powerShellCompletion, cli, psQuote)
+}
+
+// The scripts call "<cli> __complete <words...> <word being completed>" and
+// read the candidates written by Cli.writeCompletions
+
+const bashCompletion = `# bash completion for {{NAME}}
+# load with: source <({{NAME}} completion bash)
+
+_{{FUNC}}_completion() {
+    local line="${COMP_LINE:0:COMP_POINT}"
+    local -a words
+    read -ra words <<< "${line}"
+    if [[ "${line}" == *[[:space:]] ]]; then
+        words+=("")
+    fi
+    local cur="${words[${#words[@]}-1]}"
+
+    # bash splits words on "=" and ":", so only the part of the word after
+    # the last break is replaced by the completion
+    local prefix="${cur%"${COMP_WORDS[COMP_CWORD]}"}"
+
+    local directive=0 out
+    local -a values=()
+    while IFS='' read -r out; do
+        if [[ "${out}" == :* ]]; then
+            directive="${out#:}"
+        elif [[ -n "${out}" ]]; then
+            out="${out%%$'\t'*}"
+            values+=("${out#"${prefix}"}")
+        fi
+    done < <({{QUOTED_NAME}} {{COMPLETE}} "${words[@]:1}" 2>/dev/null)
+
+    if (( directive & {{DIRS}} )); then
+        COMPREPLY=($(compgen -d -- "${COMP_WORDS[COMP_CWORD]}"))
+        return
+    fi
+    if (( directive & {{FILES}} )); then
+        COMPREPLY=($(compgen -f -- "${COMP_WORDS[COMP_CWORD]}"))
+        return
+    fi
+    if (( ${#values[@]} == 0 )); then
+        if (( (directive & {{NO_FILES}}) == 0 )); then
+            COMPREPLY=($(compgen -f -- "${COMP_WORDS[COMP_CWORD]}"))
+        fi
+        return
+    fi
+    if (( directive & {{NO_SPACE}} )); then
+        compopt -o nospace 2>/dev/null
+    fi
+    COMPREPLY=("${values[@]}")
+}
+
+complete -F _{{FUNC}}_completion {{QUOTED_NAME}}
+`
+
+const zshCompletion = `#compdef {{NAME}}
+# zsh completion for {{NAME}}
+# load with: source <({{NAME}} completion zsh)
+
+_{{FUNC}}() {
+    local directive=0 out value desc
+    local -a candidates
+    for out in "${(@f)$({{QUOTED_NAME}} {{COMPLETE}} "${(@)words[2,CURRENT]}" 2>/dev/null)}"; do
+        if [[ "${out}" == :* ]]; then
+            directive="${out#:}"
+        elif [[ -n "${out}" ]]; then
+            value="${out%%$'\t'*}"
+            desc=""
+            if [[ "${out}" == *$'\t'* ]]; then
+                desc="${out#*$'\t'}"
+            fi
+            # colons separate the candidate from its description
+            candidates+=("${value//:/\\:}:${desc}")
+        fi
+    done
+
+    if (( directive & {{DIRS}} )); then
+        _files -/
+        return
+    fi
+    if (( directive & {{FILES}} )); then
+        _files
+        return
+    fi
+    if (( ${#candidates} == 0 )); then
+        if (( (directive & {{NO_FILES}}) == 0 )); then
+            _files
+        fi
+        return
+    fi
+    if (( directive & {{NO_SPACE}} )); then
+        _describe 'completions' candidates -S ''
+    else
+        _describe 'completions' candidates
+    fi
+}
+
+if [ "$funcstack[1]" = "_{{FUNC}}" ]; then
+    _{{FUNC}} "$@"
+else
+    compdef _{{FUNC}} {{QUOTED_NAME}}
+fi
+`
+
+const fishCompletion = `# fish completion for {{NAME}}
+# load with: {{NAME}} completion fish | source
+
+function __{{FUNC}}_complete
+    set -l cur (commandline -ct)
+    if test (count $cur) -eq 0
+        set cur ''
+    end
+    set -l lines ({{QUOTED_NAME}} {{COMPLETE}} (commandline -opc)[2..-1] $cur 2>/dev/null)
+
+    set -l directive 0
+    if test (count $lines) -gt 0; and string match -q -r '^:[0-9]+$' -- $lines[-1]
+        set directive (string sub -s 2 -- $lines[-1])
+        set -e lines[-1]
+    end
+
+    if test (math "bitand($directive, {{DIRS}})") -ne 0
+        __fish_complete_directories $cur
+        return
+    end
+    if test (math "bitand($directive, {{FILES}})") -ne 0
+        __fish_complete_path $cur
+        return
+    end
+    if test (count $lines) -eq 0
+        if test (math "bitand($directive, {{NO_FILES}})") -eq 0
+            __fish_complete_path $cur
+        end
+        return
+    end
+    printf '%s\n' $lines
+end
+
+complete -c {{QUOTED_NAME}} -f -a '(__{{FUNC}}_complete)'
+`
+
+const powerShellCompletion = `# powershell completion for {{NAME}}
+# load with: {{NAME}} completion powershell | Out-String | Invoke-Expression
+
+Register-ArgumentCompleter -Native -CommandName {{QUOTED_NAME}} -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -ne '') {
+        $words = @($words | Select-Object -First ($words.Count - 1))
+    }
+
+    # older versions of PowerShell drop empty arguments to native commands
+    $current = $wordToComplete
+    if ($current -eq '' -and ($PSVersionTable.PSVersion -lt [version]'7.3.0' -or $PSNativeCommandArgumentPassing -eq 'Legacy')) {
+        $current = '""'
+    }
+
+    $lines = @(& {{QUOTED_NAME}} {{COMPLETE}} @words $current 2>$null)
+    $directive = 0
+    if ($lines.Count -gt 0 -and $lines[-1] -match '^:(\d+)$') {
+        $directive = [int]$Matches[1]
+        $lines = @($lines | Select-Object -First ($lines.Count - 1))
+    }
+
+    # file and directory completion is left to PowerShell
+    if ($directive -band ({{FILES}} -bor {{DIRS}})) {
+        return
+    }
+
+    foreach ($line in $lines) {
+        if ($line -eq '') {
+            continue
+        }
+        $value, $description = $line -split "` + "`" + `t", 2
+        if (-not $description) {
+            $description = $value
+        }
+        $text = $value
+        if (-not ($directive -band {{NO_SPACE}})) {
+            $text = "$value "
+        }
+        [System.Management.Automation.CompletionResult]::new($text, $value, 'ParameterValue', $description)
+    }
+}
+`
+
+// Built-in command which prints a completion script for the CLI
+func (cli *Cli) completionCommand() *Command {
+	return &Command{
+		Name:      "completion",
+		ShortDesc: "Generate a shell completion script",
+		LongDesc: "Print a completion script for the given shell. For example, add " + Sep() +
+			Sep() + "  source <(" + cli.Entrypoint.Name + " completion bash)" + Sep() +
+			Sep() + "to your ~/.bashrc to load the completions in every bash session.",
+		Argument: Argument{
+			Name:        "shell",
+			Required:    true,
+			Description: "One of '" + strings.Join(CompletionShells, "', '") + "'",
+			Complete: func(ctx Context, toComplete string) ([]Completion, CompletionDirective) {
+				completions := []Completion{}
+				for _, shell := range CompletionShells {
+					completions = append(completions, Completion{Value: shell})
+				}
+				return completions, CompletionNoFiles
+			},
+		},
+		action: func(ctx Context) error {
+			args, err := ParseArgs(ctx.Options, ctx.Command.Argument, ctx.StrArgs)
+			if err != nil {
+				return err
+			}
+			return cli.GenCompletion(args["shell"].(string), os.Stdout)
+		},
+	}
+}
@@ -0,0 +1,141 @@
+package gocli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func completionCli() Cli {
+	root := Command{Name: "root"}
+	run := Command{
+		Name:      "run",
+		ShortDesc: "Run it",
+		Options: &[]Option{
+			{Short: "n", Type: "int", Description: "How many"},
+			{Short: "l", Long: "label", Type: "string", Description: "Label"},
+			{Long: "verbose", Type: "bool", Description: "Verbose"},
+			{
+				Short: "c",
+				Long:  "cluster",
+				Type:  "string",
+				Complete: CompleteWith(func(ctx Context, toComplete string) ([]Completion, CompletionDirective) {
+					return []Completion{{Value: "prod", Description: "Production"}, {Value: "staging"}}, CompletionNoFiles
+				}),
+			},
+		},
+		Argument: Argument{
+			Name: "target",
+			Complete: func(ctx Context, toComplete string) ([]Completion, CompletionDirective) {
+				// completions depend on the options which are already set
+				if ctx.Args["cluster"] == "prod" {
+					return []Completion{{Value: "web"}, {Value: "worker"}}, CompletionNoSpace
+				}
+				return []Completion{{Value: "local"}}, CompletionNoSpace
+			},
+		},
+	}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &run)
+	cli.addBuiltins()
+	return cli
+}
+
+func TestOptionComparable(t *testing.T) {
+	// options with a completion can still be compared
+	complete := CompleteWith(func(ctx Context, toComplete string) ([]Completion, CompletionDirective) {
+		return nil, CompletionDefault
+	})
+	a := Option{Long: "cluster", Complete: complete}
+	b := Option{Long: "cluster", Complete: complete}
+	if a != b || a == (Option{Long: "cluster"}) {
+		t.Errorf("comparing options with a completion failed")
+	}
+}
+
+func TestComplete(t *testing.T) {
+	cli := completionCli()
+
+	tests := []struct {
+		args      []string
+		values    []string
+		directive CompletionDirective
+	}{
+		{[]string{""}, []string{"run", "completion", "help"}, CompletionNoFiles},
+		{[]string{"r"}, []string{"run"}, CompletionNoFiles},
+		{[]string{"run", "--"}, []string{"--label", "--verbose", "--cluster", "--help"}, CompletionNoFiles},
+		{[]string{"run", "-n", ""}, []string{}, CompletionNoFiles},
+		{[]string{"run", "-l", ""}, []string{}, CompletionDefault},
+		{[]string{"run", "--cluster", "p"}, []string{"prod"}, CompletionNoFiles},
+		{[]string{"run", "--cluster=s"}, []string{"--cluster=staging"}, CompletionNoFiles},
+		{[]string{"run", ""}, []string{"local"}, CompletionNoSpace},
+		{[]string{"run", "-c", "prod", ""}, []string{"web", "worker"}, CompletionNoSpace},
+		{[]string{"run", "-c", "prod", "wo"}, []string{"worker"}, CompletionNoSpace},
+		{[]string{"completion", ""}, []string{"bash", "zsh", "fish", "powershell"}, CompletionNoFiles},
+		{[]string{"completion", `""`}, []string{"bash", "zsh", "fish", "powershell"}, CompletionNoFiles},
+		{[]string{"help", "c"}, []string{"completion"}, CompletionNoFiles},
+		{[]string{"help", "--all", "r"}, []string{"run"}, CompletionNoFiles},
+		{[]string{"help", "run", ""}, []string{}, CompletionNoFiles},
+	}
+
+	for _, test := range tests {
+		completions, directive := cli.complete(test.args)
+		if values := completionValues(completions); !reflect.DeepEqual(values, test.values) || directive != test.directive {
+			t.Errorf("complete(%q) returned %q, %d. Expected %q, %d", test.args, values, directive, test.values, test.directive)
+		}
+	}
+}
+
+func TestWriteCompletions(t *testing.T) {
+	cli := completionCli()
+
+	b := &strings.Builder{}
+	if err := cli.writeCompletions(b, []string{"run", "--cluster", ""}); err != nil {
+		t.Errorf("writeCompletions returned an error: %s", err)
+	}
+	if expected := "prod\tProduction\nstaging\n:2\n"; b.String() != expected {
+		t.Errorf("writeCompletions wrote %q. Expected %q", b.String(), expected)
+	}
+}
+
+func TestGenCompletion(t *testing.T) {
+	cli := completionCli()
+
+	expected := map[string][]string{
+		"bash":       {"'root' __complete \"${words[@]:1}\"", "complete -F _root_completion 'root'"},
+		"zsh":        {"#compdef root", "'root' __complete \"${(@)words[2,CURRENT]}\"", "compdef _root 'root'"},
+		"fish":       {"'root' __complete (commandline -opc)[2..-1] $cur", "complete -c 'root' -f -a '(__root_complete)'"},
+		"powershell": {"Register-ArgumentCompleter -Native -CommandName 'root'", "& 'root' __complete @words $current"},
+	}
+
+	for shell, parts := range expected {
+		b := &strings.Builder{}
+		if err := cli.GenCompletion(shell, b); err != nil {
+			t.Errorf("GenCompletion(\"%s\") returned an error: %s", shell, err)
+		}
+		for _, part := range parts {
+			if !strings.Contains(b.String(), part) {
+				t.Errorf("%s completion is missing %q:\n%s", shell, part, b.String())
+			}
+		}
+		if strings.Contains(b.String(), "{{") {
+			t.Errorf("%s completion has unfilled placeholders:\n%s", shell, b.String())
+		}
+	}
+
+	if err := cli.GenCompletion("tcsh", &strings.Builder{}); err == nil {
+		t.Errorf("GenCompletion(\"tcsh\") did not return an error")
+	}
+}
+
+func TestCompletionQuoting(t *testing.T) {
+	if s := shQuote("it's"); s != `'it'\''s'` {
+		t.Errorf("shQuote(\"it's\") returned %s", s)
+	}
+	if s := fishQuote(`a\b'c`); s != `'a\\b\'c'` {
+		t.Errorf("fishQuote returned %s", s)
+	}
+	if s := psQuote("it's"); s != "'it''s'" {
+		t.Errorf("psQuote(\"it's\") returned %s", s)
+	}
+}
@@ -0,0 +1,377 @@
+package gocli
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A link to the documentation of another command
+type docLink struct {
+	Name        string
+	Link        string
+	Description string
+}
+
+// An option as shown in the documentation
+type docOption struct {
+	Flags       []string
+	Type        string
+	Required    bool
+	Description string
+}
+
+// The documentation of a command, shared by the markdown and HTML formats
+type docCommand struct {
+	// Full name of the command, e.g. "example run"
+	Name string
+
+	// Anchor of the command in the single page reference
+	Anchor string
+
+	ShortDesc string
+	LongDesc  string
+	Usage     string
+	Parent    *docLink
+	Children  []docLink
+	Options   []docOption
+	Argument  *Argument
+	Examples  []Example
+	Sections  []Section
+	SeeAlso   []docLink
+}
+
+// Name of the documentation file of a command, without the extension
+func docFilename(path []string) string {
+	return strings.Join(path, "_")
+}
+
+// Anchor of a heading, as generated by GitHub for markdown
+func docAnchor(heading string) string {
+	heading = strings.ToLower(heading)
+	heading = regexp.MustCompile(`[^\p{L}\p{N} _-]`).ReplaceAllString(heading, "")
+	return strings.ReplaceAll(heading, " ", "-")
+}
+
+// Build the documentation of a command. "link" returns the link to the
+// documentation of the command at the given path
+func (cli *Cli) docCommand(cmd *Command, path []string, link func(path []string) string) docCommand {
+	children := cli.listedChildren(cmd)
+	options := cli.options(cmd)
+	name := strings.Join(path, " ")
+
+	doc := docCommand{
+		Name:      name,
+		Anchor:    docAnchor(name),
+		ShortDesc: cmd.ShortDesc,
+		LongDesc:  cmd.LongDesc,
+		Usage:     name + usageSuffix(cmd, children, options),
+	}
+
+	if len(path) > 1 {
+		parentPath := path[:len(path)-1]
+		doc.Parent = &docLink{Name: strings.Join(parentPath, " "), Link: link(parentPath)}
+	}
+	for _, child := range children {
+		childPath := append(append([]string{}, path...), child.Name)
+		doc.Children = append(doc.Children, docLink{
+			Name:        child.Name,
+			Link:        link(childPath),
+			Description: child.ShortDesc,
+		})
+	}
+	for _, opt := range visibleOptions(options) {
+		doc.Options = append(doc.Options, docOption{
+			Flags:       optionFlags(opt),
+			Type:        opt.Type,
+			Required:    opt.Required,
+			Description: opt.Description,
+		})
+	}
+	if cmd.Argument.Name != "" {
+		arg := cmd.Argument
+		doc.Argument = &arg
+	}
+	doc.Examples = cmd.Examples
+	doc.Sections = cmd.Sections
+
+	// related commands are linked if they are in the tree
+	for _, related := range cmd.SeeAlso {
+		relatedLink := docLink{Name: related}
+		if words := strings.Fields(related); len(words) > 0 && words[0] == cli.Entrypoint.Name {
+			if relatedCmd, _, rest := cli.route(words[1:]); len(rest) == 0 {
+				relatedLink.Link = link(cli.commandPath(relatedCmd))
+			}
+		}
+		doc.SeeAlso = append(doc.SeeAlso, relatedLink)
+	}
+
+	return doc
+}
+
+// Escape text for a cell of a markdown table
+func mdCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "<br>"), "\n", "<br>")
+}
+
+// Returns "Yes" or "No"
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
+
+// Write the markdown documentation of a command. "level" is the level of the
+// command's heading
+func writeMarkdown(w io.Writer, doc docCommand, level int) error {
+	h := func(offset int) string {
+		return strings.Repeat("#", level+offset) + " "
+	}
+
+	b := &strings.Builder{}
+	b.WriteString(h(0) + doc.Name + "\n\n")
+	if doc.ShortDesc != "" {
+		b.WriteString(doc.ShortDesc + "\n\n")
+	}
+	if doc.LongDesc != "" {
+		b.WriteString(doc.LongDesc + "\n\n")
+	}
+
+	b.WriteString(h(1) + "Usage\n\n")
+	b.WriteString("```\n" + doc.Usage + "\n```\n\n")
+
+	if len(doc.Children) > 0 {
+		b.WriteString(h(1) + "Commands\n\n")
+		b.WriteString("| Command | Description |\n")
+		b.WriteString("| --- | --- |\n")
+		for _, child := range doc.Children {
+			fmt.Fprintf(b, "| [%s](%s) | %s |\n", mdCell(child.Name), child.Link, mdCell(child.Description))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(doc.Options) > 0 {
+		b.WriteString(h(1) + "Options\n\n")
+		b.WriteString("| Option | Type | Required | Description |\n")
+		b.WriteString("| --- | --- | --- | --- |\n")
+		for _, opt := range doc.Options {
+			fmt.Fprintf(b, "| `%s` | %s | %s | %s |\n", strings.Join(opt.Flags, "`, `"), opt.Type, yesNo(opt.Required), mdCell(opt.Description))
+		}
+		b.WriteString("\n")
+	}
+
+	if arg := doc.Argument; arg != nil {
+		b.WriteString(h(1) + "Arguments\n\n")
+		b.WriteString("| Argument | Required | Description |\n")
+		b.WriteString("| --- | --- | --- |\n")
+		fmt.Fprintf(b, "| `%s` | %s | %s |\n\n", arg.Name, yesNo(arg.Required), mdCell(arg.Description))
+	}
+
+	if len(doc.Examples) > 0 {
+		b.WriteString(h(1) + "Examples\n\n")
+		for _, example := range doc.Examples {
+			if example.Description != "" {
+				b.WriteString(example.Description + "\n\n")
+			}
+			b.WriteString("```\n" + example.Command + "\n```\n\n")
+		}
+	}
+
+	for _, section := range doc.Sections {
+		b.WriteString(h(1) + section.Title + "\n\n")
+		b.WriteString(section.Body + "\n\n")
+	}
+
+	if doc.Parent != nil || len(doc.SeeAlso) > 0 {
+		b.WriteString(h(1) + "See also\n\n")
+		if doc.Parent != nil {
+			fmt.Fprintf(b, "- [%s](%s)\n", doc.Parent.Name, doc.Parent.Link)
+		}
+		for _, related := range doc.SeeAlso {
+			if related.Link != "" {
+				fmt.Fprintf(b, "- [%s](%s)\n", related.Name, related.Link)
+			} else {
+				fmt.Fprintf(b, "- %s\n", related.Name)
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Write the markdown documentation of a command to w. Links point to the
+// files written by GenMarkdownTree
+func (cli *Cli) GenMarkdown(cmd *Command, w io.Writer) error {
+	path := cli.commandPath(cmd)
+	if path == nil {
+		return fmt.Errorf("Command \"%s\" is not in the CLI tree.", cmd.Name)
+	}
+	link := func(path []string) string {
+		return docFilename(path) + ".md"
+	}
+	return writeMarkdown(w, cli.docCommand(cmd, path, link), 1)
+}
+
+// Write a markdown page for every command of the CLI into dir. The pages are
+// named after the command path, e.g. "example_run.md"
+func (cli *Cli) GenMarkdownTree(dir string) error {
+	return cli.genDocTree(dir, ".md", (*Cli).GenMarkdown)
+}
+
+// Write the documentation of every command of the CLI to w as a single
+// markdown page
+func (cli *Cli) GenMarkdownReference(w io.Writer) error {
+	cli = cli.withBuiltins()
+	link := func(path []string) string {
+		return "#" + docAnchor(strings.Join(path, " "))
+	}
+
+	var err error
+	cli.walkListed(func(cmd *Command, parents []string) {
+		if err != nil {
+			return
+		}
+		err = writeMarkdown(w, cli.docCommand(cmd, append(parents, cmd.Name), link), 2)
+	})
+	return err
+}
+
+var htmlDoc = template.Must(template.New("command").Funcs(template.FuncMap{
+	"yesNo": yesNo,
+}).Parse(`{{define "command"}}<h1 id="{{.Anchor}}">{{.Name}}</h1>
+{{- if .ShortDesc}}
+<p>{{.ShortDesc}}</p>
+{{- end}}
+{{- if .LongDesc}}
+<p>{{.LongDesc}}</p>
+{{- end}}
+<h2>Usage</h2>
+<pre><code>{{.Usage}}</code></pre>
+{{- if .Children}}
+<h2>Commands</h2>
+<table>
+<tr><th>Command</th><th>Description</th></tr>
+{{- range .Children}}
+<tr><td><a href="{{.Link}}">{{.Name}}</a></td><td>{{.Description}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Options}}
+<h2>Options</h2>
+<table>
+<tr><th>Option</th><th>Type</th><th>Required</th><th>Description</th></tr>
+{{- range .Options}}
+<tr><td>{{range $i, $flag := .Flags}}{{if $i}}, {{end}}<code>{{$flag}}</code>{{end}}</td><td>{{.Type}}</td><td>{{yesNo .Required}}</td><td>{{.Description}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with .Argument}}
+<h2>Arguments</h2>
+<table>
+<tr><th>Argument</th><th>Required</th><th>Description</th></tr>
+<tr><td><code>{{.Name}}</code></td><td>{{yesNo .Required}}</td><td>{{.Description}}</td></tr>
+</table>
+{{- end}}
+{{- if .Examples}}
+<h2>Examples</h2>
+{{- range .Examples}}
+{{- if .Description}}
+<p>{{.Description}}</p>
+{{- end}}
+<pre><code>{{.Command}}</code></pre>
+{{- end}}
+{{- end}}
+{{- range .Sections}}
+<h2>{{.Title}}</h2>
+<p>{{.Body}}</p>
+{{- end}}
+{{- if or .Parent .SeeAlso}}
+<h2>See also</h2>
+<ul>
+{{- with .Parent}}
+<li><a href="{{.Link}}">{{.Name}}</a></li>
+{{- end}}
+{{- range .SeeAlso}}
+<li>{{if .Link}}<a href="{{.Link}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{end}}
+{{define "page"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+{{range .Commands}}{{template "command" .}}{{end -}}
+</body>
+</html>
+{{end}}`))
+
+// Write an HTML page documenting the commands
+func writeHTML(w io.Writer, title string, docs []docCommand) error {
+	return htmlDoc.ExecuteTemplate(w, "page", struct {
+		Title    string
+		Commands []docCommand
+	}{title, docs})
+}
+
+// Write the HTML documentation of a command to w. Links point to the files
+// written by GenHTMLTree
+func (cli *Cli) GenHTML(cmd *Command, w io.Writer) error {
+	path := cli.commandPath(cmd)
+	if path == nil {
+		return fmt.Errorf("Command \"%s\" is not in the CLI tree.", cmd.Name)
+	}
+	link := func(path []string) string {
+		return docFilename(path) + ".html"
+	}
+	doc := cli.docCommand(cmd, path, link)
+	return writeHTML(w, doc.Name, []docCommand{doc})
+}
+
+// Write an HTML page for every command of the CLI into dir. The pages are
+// named after the command path, e.g. "example_run.html"
+func (cli *Cli) GenHTMLTree(dir string) error {
+	return cli.genDocTree(dir, ".html", (*Cli).GenHTML)
+}
+
+// Write the documentation of every command of the CLI to w as a single
+// HTML page
+func (cli *Cli) GenHTMLReference(w io.Writer) error {
+	cli = cli.withBuiltins()
+	link := func(path []string) string {
+		return "#" + docAnchor(strings.Join(path, " "))
+	}
+
+	docs := []docCommand{}
+	cli.walkListed(func(cmd *Command, parents []string) {
+		docs = append(docs, cli.docCommand(cmd, append(parents, cmd.Name), link))
+	})
+	return writeHTML(w, cli.Entrypoint.Name+" reference", docs)
+}
+
+// Write a page for every command of the CLI into dir with "gen"
+func (cli *Cli) genDocTree(dir string, ext string, gen func(cli *Cli, cmd *Command, w io.Writer) error) error {
+	cli = cli.withBuiltins()
+
+	var err error
+	cli.walkListed(func(cmd *Command, parents []string) {
+		if err != nil {
+			return
+		}
+		filename := filepath.Join(dir, docFilename(append(parents, cmd.Name))+ext)
+		err = writeFile(filename, func(w io.Writer) error {
+			return gen(cli, cmd, w)
+		})
+	})
+	return err
+}
@@ -0,0 +1,131 @@
+package gocli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func docsCli() (Cli, *Command) {
+	root := Command{Name: "root", LongDesc: "The root command"}
+	run := Command{
+		Name:      "run",
+		ShortDesc: "Run it",
+		Options: &[]Option{
+			{Short: "l", Long: "label", Type: "string", Description: "Label | name", Required: true},
+		},
+		Argument: Argument{Name: "target", Description: "Where to <run>"},
+	}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &run)
+	return cli, &run
+}
+
+func TestGenMarkdown(t *testing.T) {
+	cli, run := docsCli()
+
+	b := &strings.Builder{}
+	if err := cli.GenMarkdown(run, b); err != nil {
+		t.Errorf("GenMarkdown returned an error: %s", err)
+	}
+
+	expected := []string{
+		"# root run\n\nRun it\n\n",
+		"## Usage\n\n```\nroot run [OPTIONS] target\n```\n",
+		"| `-l`, `--label` | string | Yes | Label \\| name |\n",
+		"| `-h`, `--help` | bool | No | Print a help string |\n",
+		"| `target` | No | Where to <run> |\n",
+		"## See also\n\n- [root](root.md)\n",
+	}
+	for _, part := range expected {
+		if !strings.Contains(b.String(), part) {
+			t.Errorf("markdown is missing %q:\n%s", part, b.String())
+		}
+	}
+}
+
+func TestGenMarkdownReference(t *testing.T) {
+	cli, _ := docsCli()
+
+	b := &strings.Builder{}
+	if err := cli.GenMarkdownReference(b); err != nil {
+		t.Errorf("GenMarkdownReference returned an error: %s", err)
+	}
+
+	expected := []string{
+		"## root\n",
+		"| [run](#root-run) | Run it |\n",
+		"## root run\n",
+		"### Usage\n",
+		"- [root](#root)\n",
+	}
+	for _, part := range expected {
+		if !strings.Contains(b.String(), part) {
+			t.Errorf("markdown reference is missing %q:\n%s", part, b.String())
+		}
+	}
+
+	// the output does not change between runs
+	b2 := &strings.Builder{}
+	cli.GenMarkdownReference(b2)
+	if b.String() != b2.String() {
+		t.Errorf("GenMarkdownReference is not deterministic")
+	}
+}
+
+func TestGenHTML(t *testing.T) {
+	cli, run := docsCli()
+
+	b := &strings.Builder{}
+	if err := cli.GenHTML(run, b); err != nil {
+		t.Errorf("GenHTML returned an error: %s", err)
+	}
+
+	expected := []string{
+		"<title>root run</title>",
+		`<h1 id="root-run">root run</h1>`,
+		"<td>Where to &lt;run&gt;</td>",
+		`<li><a href="root.html">root</a></li>`,
+	}
+	for _, part := range expected {
+		if !strings.Contains(b.String(), part) {
+			t.Errorf("html is missing %q:\n%s", part, b.String())
+		}
+	}
+}
+
+func TestGenDocTrees(t *testing.T) {
+	cli, _ := docsCli()
+	cli.AddChild(cli.Entrypoint, &Command{Name: "secret", Hidden: true})
+
+	dir := t.TempDir()
+	if err := cli.GenMarkdownTree(dir); err != nil {
+		t.Errorf("GenMarkdownTree returned an error: %s", err)
+	}
+	if err := cli.GenHTMLTree(dir); err != nil {
+		t.Errorf("GenHTMLTree returned an error: %s", err)
+	}
+
+	for _, name := range []string{"root.md", "root_run.md", "root_completion.md", "root.html", "root_run.html"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was not written: %s", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "root_secret.md")); err == nil {
+		t.Errorf("root_secret.md was written for a hidden command")
+	}
+
+	// the built-in commands are documented without being added to the CLI
+	for _, child := range cli.childrenMap[cli.Entrypoint] {
+		if child.Name == "help" || child.Name == "completion" {
+			t.Errorf("the doc generators added '%s' to the CLI", child.Name)
+		}
+	}
+}
+
+func TestDocAnchor(t *testing.T) {
+	if a := docAnchor("example(.exe) run"); a != "exampleexe-run" {
+		t.Errorf("docAnchor(\"example(.exe) run\") returned %s", a)
+	}
+}
@@ -61,6 +61,19 @@ var childCmd = Command{
 	Options:   &[]Option{num, verbose, label},
 	Argument:  dir,
 	Behavior:  childBehavior,
+	Examples: []Example{
+		{
+			Command:     "example(.exe) run -n 2 ~/projects",
+			Description: "Print ~/projects and its parent directory",
+		},
+		{
+			Command:     "example(.exe) run --verbose --label=steps -n 3 '/tmp/my dir'",
+			Description: "Stream the output of each step with a label",
+		},
+	},
+	Sections: []Section{
+		{Title: "Exit Codes", Body: "0 on success, 1 if a directory does not exist."},
+	},
 }
 
 //////////////////////////////////////////
@@ -85,8 +98,9 @@ func childBehavior(ctx Context) {
 	// Read cli argument 'directory'
 	dir := ctx.Args["directory"].(string)
 
-	// build the bash command
-	cmd := fmt.Sprintf("cd %s", dir)
+	// build the bash command. The directory is quoted, so that paths with
+	// spaces or special characters are passed to cd as they are
+	cmd := NewShellCommand("cd", dir).String()
 	for i := 0; i < n; i++ {
 		cmd = fmt.Sprintf(`
 		%s
@@ -0,0 +1,112 @@
+package gocli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Split a command line into words like a POSIX shell, handling single
+// quotes, double quotes and backslash escapes. Variables, globs and other
+// expansions are not performed
+func splitCommandLine(line string) ([]string, error) {
+	words := []string{}
+	word := strings.Builder{}
+	inWord := false
+	var quote rune
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' && i+1 < len(runes) && strings.ContainsRune("\\\"$`\n", runes[i+1]) {
+				i++
+				if runes[i] != '\n' {
+					word.WriteRune(runes[i])
+				}
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				// a backslash-newline continues the line
+				if runes[i] != '\n' {
+					word.WriteRune(runes[i])
+					inWord = true
+				}
+			}
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return words, fmt.Errorf("Unterminated %c quote in `%s`", quote, line)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// Check that the example is a valid invocation of cmd
+func (cli *Cli) validateExample(cmd *Command, example Example) error {
+	words, err := splitCommandLine(example.Command)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 || words[0] != cli.Entrypoint.Name {
+		return fmt.Errorf("Example does not start with '%s'", cli.Entrypoint.Name)
+	}
+
+	routed, _, args := cli.route(words[1:])
+	if routed != cmd {
+		return fmt.Errorf("Example runs '%s' instead of '%s'", routed.Name, cmd.Name)
+	}
+
+	_, err = ParseArgs(cli.options(cmd), cmd.Argument, args)
+	return err
+}
+
+// Check that the Examples of every command parse against the command's
+// options and argument. Meant to be called from a test:
+//
+//	func TestExamples(t *testing.T) {
+//		if err := cli.ValidateExamples(); err != nil {
+//			t.Error(err)
+//		}
+//	}
+func (cli *Cli) ValidateExamples() error {
+	problems := []string{}
+	cli.walk(func(cmd *Command, parents []string) {
+		for _, example := range cmd.Examples {
+			if err := cli.validateExample(cmd, example); err != nil {
+				problems = append(problems, fmt.Sprintf("`%s`: %s", example.Command, err))
+			}
+		}
+	})
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Invalid examples:%s  %s", Sep(), strings.Join(problems, Sep()+"  "))
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package gocli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := map[string][]string{
+		"":                         {},
+		"a b  c":                   {"a", "b", "c"},
+		`a 'b c' "d e"`:            {"a", "b c", "d e"},
+		`a 'it'\''s' "say \"hi\""`: {"a", "it's", `say "hi"`},
+		`a b\ c "\$x" '\n'`:        {"a", "b c", "$x", `\n`},
+		"a \\\n b":                 {"a", "b"},
+		`--label="" x`:             {"--label=", "x"},
+		`x ''`:                     {"x", ""},
+	}
+	for line, expected := range tests {
+		words, err := splitCommandLine(line)
+		if err != nil || !reflect.DeepEqual(words, expected) {
+			t.Errorf("splitCommandLine(%q) returned %q, %v. Expected %q", line, words, err, expected)
+		}
+	}
+
+	if _, err := splitCommandLine(`a "b`); err == nil {
+		t.Errorf("splitCommandLine did not return an error for an unterminated quote")
+	}
+}
+
+func TestValidateExamples(t *testing.T) {
+	cli := NewCli(&rootCmd)
+	cli.AddChild(&rootCmd, &childCmd)
+	if err := cli.ValidateExamples(); err != nil {
+		t.Errorf("the examples of example.go are invalid: %s", err)
+	}
+
+	root := Command{Name: "root"}
+	run := Command{
+		Name:     "run",
+		Options:  &[]Option{{Short: "n", Type: "int", Required: true}},
+		Argument: Argument{Name: "dir"},
+		Examples: []Example{
+			{Command: "root run -n 1 ."},
+			{Command: "root run -n x"},
+			{Command: "root run ."},
+			{Command: "other run -n 1"},
+			{Command: "root -n 1"},
+		},
+	}
+	cli = NewCli(&root)
+	cli.AddChild(&root, &run)
+
+	err := cli.ValidateExamples()
+	if err == nil {
+		t.Fatalf("ValidateExamples did not return an error for invalid examples")
+	}
+	for _, example := range run.Examples[1:] {
+		if !strings.Contains(err.Error(), "`"+example.Command+"`") {
+			t.Errorf("ValidateExamples did not report `%s`: %s", example.Command, err)
+		}
+	}
+	if strings.Contains(err.Error(), "`root run -n 1 .`") {
+		t.Errorf("ValidateExamples reported a valid example: %s", err)
+	}
+}
+
+func TestExamplesInHelp(t *testing.T) {
+	setTerminalWidth(t, 200)
+
+	cmd := Command{
+		Name: "run",
+		Examples: []Example{
+			{Command: "root run -n 1", Description: "Run once"},
+			{Command: "root run -n 2"},
+		},
+		Sections: []Section{{Title: "Environment", Body: "ROOT_HOME sets the home directory"}},
+		SeeAlso:  []string{"root stop", "root start"},
+	}
+	ctx := helpContext(&cmd, "root run", nil, nil)
+	help := strings.ReplaceAll(ctx.HelpStr(), Sep(), "\n")
+
+	expected := "Examples:\n" +
+		"  # Run once\n" +
+		"  root run -n 1\n" +
+		"\n" +
+		"  root run -n 2\n" +
+		"\n" +
+		"Environment:\n" +
+		"  ROOT_HOME sets the home directory\n" +
+		"\n" +
+		"See also:\n" +
+		"  root stop, root start\n" +
+		"\n"
+	if !strings.HasSuffix(help, expected) {
+		t.Errorf("HelpStr returned\n%s\nExpected it to end with\n%s", help, expected)
+	}
+
+	// a blank line separates the argument from the examples
+	cmd.Argument = Argument{Name: "dir", Description: "The directory"}
+	help = strings.ReplaceAll(ctx.HelpStr(), Sep(), "\n")
+	if !strings.Contains(help, "The directory\n\nExamples:\n") {
+		t.Errorf("HelpStr did not separate the argument and the examples:\n%s", help)
+	}
+}
+
+func TestExamplesInDocs(t *testing.T) {
+	root := Command{Name: "root"}
+	run := Command{
+		Name:     "run",
+		Examples: []Example{{Command: "root run -n 1", Description: "Run once"}},
+		Sections: []Section{{Title: "Exit Codes", Body: "0 on success"}},
+		SeeAlso:  []string{"root stop", "other"},
+	}
+	stop := Command{Name: "stop"}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &run)
+	cli.AddChild(&root, &stop)
+
+	b := &strings.Builder{}
+	cli.GenMan(&run, ManHeader{}, b)
+	for _, part := range []string{
+		".SH EXAMPLES\n.PP\nRun once\n.PP\n.RS\n.nf\nroot run \\-n 1\n.fi\n.RE\n",
+		".SH \"EXIT CODES\"\n0 on success\n",
+		"\\fBroot\\fP(1), \\fBroot\\-stop\\fP(1), \\fBother\\fP(1)",
+	} {
+		if !strings.Contains(b.String(), part) {
+			t.Errorf("man page is missing %q:\n%s", part, b.String())
+		}
+	}
+
+	b = &strings.Builder{}
+	cli.GenMarkdown(&run, b)
+	for _, part := range []string{
+		"## Examples\n\nRun once\n\n```\nroot run -n 1\n```\n",
+		"## Exit Codes\n\n0 on success\n",
+		"- [root](root.md)\n- [root stop](root_stop.md)\n- other\n",
+	} {
+		if !strings.Contains(b.String(), part) {
+			t.Errorf("markdown is missing %q:\n%s", part, b.String())
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package gocli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// The data passed to the help templates
+type HelpData struct {
+	// The full usage line, e.g. "example run [OPTIONS] directory"
+	Usage string
+
+	// The collective name of the command, e.g. "example run"
+	Referrer string
+
+	Command *Command
+
+	// The sub-commands, sorted by Command.Order
+	Children []*Command
+
+	// The sub-commands grouped by Command.Group. Commands without a group
+	// come first, in a group named "Commands"
+	CommandGroups []CommandGroup
+
+	// The plugins found for the command, see Cli.EnablePlugins
+	Plugins []Plugin
+
+	// Every option of the command (including the global options), grouped by
+	// Option.Group. Options without a group come first, in a group named
+	// "Options"
+	OptionGroups []OptionGroup
+
+	// The options which every command has, e.g. "--help"
+	GlobalOptions []Option
+
+	// The argument of the command, or nil if it has none
+	Argument *Argument
+
+	Examples []Example
+	Sections []Section
+	SeeAlso  []string
+
+	// Width of the commands column and of the options column, including the
+	// padding between the column and the descriptions
+	CommandWidth int
+	OptionWidth  int
+
+	// Width of the terminal
+	Width int
+}
+
+// Commands which are shown together in the help string
+type CommandGroup struct {
+	Name     string
+	Commands []*Command
+}
+
+// Options which are shown together in the help string
+type OptionGroup struct {
+	Name    string
+	Options []Option
+}
+
+// Number of spaces between the names and the descriptions in the help string
+const helpPadding = 5
+
+// The default help template. Newlines are converted to Sep()
+const DefaultHelpTemplate = `Usage: {{wrap $.Width 7 .Usage}}
+{{with .Command.LongDesc}}{{wrap $.Width 0 .}}
+{{end}}
+{{with .Command.Aliases}}Aliases:
+  {{wrap $.Width 2 (join . ", ")}}
+
+{{end}}{{range .CommandGroups}}{{.Name}}:
+{{range .Commands}}  {{pad .Name $.CommandWidth}}{{wrap $.Width (add 2 $.CommandWidth) .ShortDesc}}
+{{end}}
+{{end}}{{if .Plugins}}Plugins:
+{{range .Plugins}}  {{pad .Name $.CommandWidth}}{{wrap $.Width (add 2 $.CommandWidth) .Path}}
+{{end}}
+{{end}}{{range .OptionGroups}}{{.Name}}:
+{{range .Options}}  {{pad .Name $.OptionWidth}}{{wrap $.Width (add 2 $.OptionWidth) (printf "[%s, Type: %s] %s" (required .Required) .Type .Description)}}
+{{end}}
+{{end}}{{with .Argument}}Argument: '{{.Name}}' ({{required .Required}})
+{{wrap $.Width 0 .Description}}{{if or $.Examples $.Sections $.SeeAlso}}
+
+{{end}}{{end}}{{if .Examples}}Examples:
+{{range $i, $example := .Examples}}{{if $i}}
+{{end}}{{with .Description}}  # {{wrap $.Width 4 .}}
+{{end}}  {{.Command}}
+{{end}}
+{{end}}{{range .Sections}}{{.Title}}:
+  {{wrap $.Width 2 .Body}}
+
+{{end}}{{if .SeeAlso}}See also:
+  {{wrap $.Width 2 (join .SeeAlso ", ")}}
+
+{{end}}`
+
+// Functions available in the help templates
+var helpFuncs = template.FuncMap{
+	// pad a string with spaces up to width
+	"pad": paddedName,
+
+	// "Required" or "Optional"
+	"required": func(required bool) string {
+		if required {
+			return "Required"
+		}
+		return "Optional"
+	},
+
+	"join": strings.Join,
+
+	"add": func(x int, y int) int {
+		return x + y
+	},
+
+	// wrap text to the width, indenting every line but the first, e.g.
+	// {{.Description | wrap $.Width 4}}
+	"wrap": func(width int, indent int, text string) string {
+		return wrapText(text, width, indent)
+	},
+
+	// number of columns a string takes in the terminal
+	"width": displayWidth,
+}
+
+// Parse a help template
+func parseHelpTemplate(text string) (*template.Template, error) {
+	return template.New("help").Funcs(helpFuncs).Parse(text)
+}
+
+var defaultHelpTemplate = template.Must(parseHelpTemplate(DefaultHelpTemplate))
+
+// Set the template which renders the help string of every command of the
+// CLI. Command.HelpTemplate takes precedence over it. See HelpData for the
+// data passed to the template
+func (cli *Cli) SetHelpTemplate(text string) error {
+	tmpl, err := parseHelpTemplate(text)
+	if err != nil {
+		return err
+	}
+	cli.helpTemplate = tmpl
+	return nil
+}
+
+// List the commands alphabetically in the help strings, instead of in the
+// order they were added. Command.Order takes precedence
+func (cli *Cli) SetSortCommands(alphabetical bool) {
+	cli.sortCommands = alphabetical
+}
+
+// Sort commands by Command.Order, then alphabetically or in their original
+// order
+func sortCommands(commands []*Command, alphabetical bool) []*Command {
+	sorted := append([]*Command{}, commands...)
+	sort.SliceStable(sorted, func(i int, j int) bool {
+		if sorted[i].Order != sorted[j].Order {
+			return sorted[i].Order < sorted[j].Order
+		}
+		return alphabetical && stripANSI(sorted[i].Name) < stripANSI(sorted[j].Name)
+	})
+	return sorted
+}
+
+// Group commands by Command.Group in the order of their first appearance,
+// with the ungrouped commands first
+func groupCommands(commands []*Command) []CommandGroup {
+	ungrouped := CommandGroup{Name: "Commands"}
+	groups := []CommandGroup{}
+	index := map[string]int{}
+	for _, cmd := range commands {
+		if cmd.Group == "" {
+			ungrouped.Commands = append(ungrouped.Commands, cmd)
+			continue
+		}
+		i, ok := index[cmd.Group]
+		if !ok {
+			groups = append(groups, CommandGroup{Name: cmd.Group})
+			i = len(groups) - 1
+			index[cmd.Group] = i
+		}
+		groups[i].Commands = append(groups[i].Commands, cmd)
+	}
+
+	if len(ungrouped.Commands) > 0 {
+		groups = append([]CommandGroup{ungrouped}, groups...)
+	}
+	return groups
+}
+
+// Returns the children of a command which are listed in the help strings,
+// in their order
+func (cli *Cli) listedChildren(cmd *Command) []*Command {
+	return visibleCommands(sortCommands(cli.childrenMap[cmd], cli.sortCommands))
+}
+
+func max(x int, y int) int {
+	if x > y {
+		return x
+	} else {
+		return y
+	}
+}
+
+// Pad a name with spaces up to width columns. Colors and wide characters are
+// taken into account
+func paddedName(name string, width int) (p string) {
+	p += name
+	for i := 0; i < width-displayWidth(name); i++ {
+		p += " "
+	}
+	return p
+}
+
+// Returns the part of a command's usage line which follows its name, e.g.
+// " [COMMAND] [OPTIONS] directory"
+func usageSuffix(cmd *Command, children []*Command, options []Option) (txt string) {
+	if len(children) > 0 {
+		txt += " [COMMAND]"
+	}
+
+	if len(options) > 0 {
+		txt += fmt.Sprintf(" [OPTIONS]")
+	}
+
+	if cmd.Argument.Name != "" {
+		txt += fmt.Sprintf(" %s", cmd.Argument.Name)
+	}
+	return
+}
+
+// Returns the data passed to the help template
+func (c *Context) HelpData() HelpData {
+	alphabetical := c.cli != nil && c.cli.sortCommands
+	children := visibleCommands(sortCommands(c.Children, alphabetical))
+
+	data := HelpData{
+		Usage:         c.Referrer + usageSuffix(c.Command, c.Children, c.Options),
+		Referrer:      c.Referrer,
+		Command:       c.Command,
+		Children:      children,
+		CommandGroups: groupCommands(children),
+		OptionGroups:  []OptionGroup{},
+		GlobalOptions: visibleOptions(DefaultOptions),
+		Width:         terminalWidth(),
+		Examples:      c.Command.Examples,
+		Sections:      c.Command.Sections,
+		SeeAlso:       c.Command.SeeAlso,
+	}
+
+	if c.Command.Argument.Name != "" {
+		arg := c.Command.Argument
+		data.Argument = &arg
+	}
+
+	if c.cli != nil && c.cli.plugins && c.Command.Argument.Name == "" {
+		data.Plugins = c.cli.findPlugins(strings.Split(c.Referrer, " "), c.Children)
+	}
+
+	maxWidth := 0
+	for _, child := range c.Children {
+		maxWidth = max(displayWidth(child.Name), maxWidth)
+	}
+	for _, plugin := range data.Plugins {
+		maxWidth = max(displayWidth(plugin.Name), maxWidth)
+	}
+	data.CommandWidth = maxWidth + helpPadding
+
+	// group the options in the order of their first appearance, with the
+	// ungrouped options first
+	ungrouped := OptionGroup{Name: "Options"}
+	groups := []OptionGroup{}
+	index := map[string]int{}
+	maxWidth = 0
+	for _, option := range visibleOptions(c.Options) {
+		maxWidth = max(displayWidth(option.Name()), maxWidth)
+
+		if option.Group == "" {
+			ungrouped.Options = append(ungrouped.Options, option)
+			continue
+		}
+		i, ok := index[option.Group]
+		if !ok {
+			groups = append(groups, OptionGroup{Name: option.Group})
+			i = len(groups) - 1
+			index[option.Group] = i
+		}
+		groups[i].Options = append(groups[i].Options, option)
+	}
+	if len(ungrouped.Options) > 0 {
+		data.OptionGroups = append(data.OptionGroups, ungrouped)
+	}
+	data.OptionGroups = append(data.OptionGroups, groups...)
+	data.OptionWidth = maxWidth + helpPadding
+
+	return data
+}
+
+// Returns the help string for a command
+func (c *Context) HelpStr() string {
+	tmpl := defaultHelpTemplate
+	if c.cli != nil && c.cli.helpTemplate != nil {
+		tmpl = c.cli.helpTemplate
+	}
+	if text := c.Command.HelpTemplate; text != "" {
+		var err error
+		tmpl, err = parseHelpTemplate(text)
+		if err != nil {
+			return fmt.Sprintf("Invalid help template for command '%s': %s", c.Referrer, err)
+		}
+	}
+
+	b := &strings.Builder{}
+	if err := tmpl.Execute(b, c.HelpData()); err != nil {
+		return fmt.Sprintf("Failed to render the help string for command '%s': %s", c.Referrer, err)
+	}
+
+	return strings.ReplaceAll(b.String(), "\n", Sep())
+}
+
+// Split the args of the help command into the path of a command and the
+// flags
+func splitHelpArgs(args []string) (path []string, flags []string) {
+	for _, arg := range args {
+		if isShortFlag(arg) || isLongFlag(arg) {
+			flags = append(flags, arg)
+		} else {
+			path = append(path, arg)
+		}
+	}
+	return
+}
+
+// Returns an indented list of cmd and every command below it, with their
+// short descriptions
+func (cli *Cli) helpTree(cmd *Command) string {
+	type line struct {
+		name string
+		desc string
+	}
+	lines := []line{}
+	nameWidth := 0
+
+	var walk func(cmd *Command, depth int)
+	walk = func(cmd *Command, depth int) {
+		name := strings.Repeat("  ", depth) + cmd.Name
+		nameWidth = max(displayWidth(name), nameWidth)
+		lines = append(lines, line{name: name, desc: cmd.ShortDesc})
+		for _, child := range cli.listedChildren(cmd) {
+			walk(child, depth+1)
+		}
+	}
+	walk(cmd, 0)
+
+	width := terminalWidth()
+	nameWidth += helpPadding
+	txt := []string{}
+	for _, l := range lines {
+		txt = append(txt, strings.TrimRight(paddedName(l.name, nameWidth)+wrapText(l.desc, width, nameWidth), " "))
+	}
+	return strings.ReplaceAll(strings.Join(txt, "\n"), "\n", Sep())
+}
+
+// The built-in "help" command, e.g. "example help run"
+func (cli *Cli) helpCommand() *Command {
+	return &Command{
+		Name:      "help",
+		ShortDesc: "Print the help string of a command",
+		LongDesc:  "Print the help string of the command at the given path, e.g. '" + cli.Entrypoint.Name + " help <command> <subcommand>'.",
+		Options: &[]Option{
+			{Long: "all", Type: "bool", Description: "Print every command of the CLI, or every command below the given command"},
+		},
+		Argument: Argument{
+			Name:        "command",
+			Description: "The names of the command and its parents, starting below '" + cli.Entrypoint.Name + "'",
+			Complete: func(ctx Context, toComplete string) ([]Completion, CompletionDirective) {
+				path, _ := splitHelpArgs(ctx.StrArgs)
+				cmd, _, rest := cli.route(path)
+				completions := []Completion{}
+				if len(rest) == 0 {
+					for _, child := range visibleCommands(cli.childrenMap[cmd]) {
+						for _, name := range child.names() {
+							completions = append(completions, Completion{Value: name, Description: child.ShortDesc})
+						}
+					}
+				}
+				return completions, CompletionNoFiles
+			},
+		},
+		action: func(ctx Context) error {
+			path, flags := splitHelpArgs(ctx.StrArgs)
+			args, err := ParseArgs(ctx.Options, Argument{}, flags)
+			if err != nil {
+				return err
+			}
+
+			cmd, parents, rest := cli.route(path)
+			if len(rest) > 0 {
+				return fmt.Errorf("Unknown command '%s' for '%s'.", rest[0], strings.Join(append(parents, cmd.Name), " "))
+			}
+
+			if args["all"].(bool) {
+				fmt.Println(cli.helpTree(cmd))
+			} else {
+				context := cli.context(cmd, parents)
+				fmt.Println(context.HelpStr())
+			}
+			return nil
+		},
+	}
+}
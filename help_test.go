@@ -0,0 +1,327 @@
+package gocli
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	color "github.com/fatih/color"
+)
+
+func helpContext(cmd *Command, referrer string, children []*Command, cli *Cli) Context {
+	return Context{
+		Referrer: referrer,
+		Command:  cmd,
+		Options:  commandOptions(cmd),
+		Children: children,
+		cli:      cli,
+	}
+}
+
+// Fix the terminal width for the duration of a test
+func setTerminalWidth(t *testing.T, width int) {
+	original := terminalWidth
+	terminalWidth = func() int { return width }
+	t.Cleanup(func() { terminalWidth = original })
+}
+
+// The "run" command of example.go, without the examples and sections
+func exampleRunCommand() *Command {
+	cmd := childCmd
+	cmd.Examples = nil
+	cmd.Sections = nil
+	return &cmd
+}
+
+func TestHelpStr(t *testing.T) {
+	setTerminalWidth(t, 200)
+
+	ctx := helpContext(exampleRunCommand(), "example run", nil, nil)
+	expected := "Usage: example run [OPTIONS] directory\n" +
+		"Print the working directory while ascending the filesystem 'n' times.\n" +
+		"\n" +
+		"Options:\n" +
+		"  -n               [Required, Type: int] Indicates how many steps up the file system the example will take.\n" +
+		"  -v,--verbose     [Optional, Type: bool] Run in verbose mode\n" +
+		"  -l,--label       [Optional, Type: string] Label for the output.\n" +
+		"  -h,--help        [Optional, Type: bool] Print a help string\n" +
+		"\n" +
+		"Argument: 'directory' (Required)\n" +
+		"The starting directory for the command"
+	if help := strings.ReplaceAll(ctx.HelpStr(), Sep(), "\n"); help != expected {
+		t.Errorf("HelpStr returned\n%s\nExpected\n%s", help, expected)
+	}
+
+	ctx = helpContext(&rootCmd, "example", []*Command{exampleRunCommand(), {Name: "longer-name", ShortDesc: "x"}}, nil)
+	expected = "Usage: example [COMMAND] [OPTIONS]\n" +
+		"An example of the cli-framework for Go! Try out the sub-command.\n" +
+		"\n" +
+		"Commands:\n" +
+		"  run             Run the example\n" +
+		"  longer-name     x\n" +
+		"\n" +
+		"Options:\n" +
+		"  -h,--help     [Optional, Type: bool] Print a help string\n" +
+		"\n"
+	if help := strings.ReplaceAll(ctx.HelpStr(), Sep(), "\n"); help != expected {
+		t.Errorf("HelpStr returned\n%s\nExpected\n%s", help, expected)
+	}
+}
+
+func TestHelpStrWrap(t *testing.T) {
+	setTerminalWidth(t, 60)
+
+	ctx := helpContext(exampleRunCommand(), "example run", nil, nil)
+	expected := "Usage: example run [OPTIONS] directory\n" +
+		"Print the working directory while ascending the filesystem\n" +
+		"'n' times.\n" +
+		"\n" +
+		"Options:\n" +
+		"  -n               [Required, Type: int] Indicates how many\n" +
+		"                   steps up the file system the example will\n" +
+		"                   take.\n" +
+		"  -v,--verbose     [Optional, Type: bool] Run in verbose\n" +
+		"                   mode\n" +
+		"  -l,--label       [Optional, Type: string] Label for the\n" +
+		"                   output.\n" +
+		"  -h,--help        [Optional, Type: bool] Print a help\n" +
+		"                   string\n" +
+		"\n" +
+		"Argument: 'directory' (Required)\n" +
+		"The starting directory for the command"
+	if help := strings.ReplaceAll(ctx.HelpStr(), Sep(), "\n"); help != expected {
+		t.Errorf("HelpStr returned\n%s\nExpected\n%s", help, expected)
+	}
+}
+
+func TestHelpAlignment(t *testing.T) {
+	setTerminalWidth(t, 200)
+
+	// colored and wide names are aligned by their display width
+	noColor := color.NoColor
+	color.NoColor = false
+	defer func() { color.NoColor = noColor }()
+
+	root := Command{Name: "root"}
+	children := []*Command{
+		{Name: Blue("run"), ShortDesc: "a"},
+		{Name: "日本", ShortDesc: "b"},
+		{Name: "stop", ShortDesc: "c"},
+	}
+	ctx := helpContext(&root, "root", children, nil)
+	help := strings.ReplaceAll(ctx.HelpStr(), Sep(), "\n")
+
+	for _, line := range []string{
+		"  " + Blue("run") + "      a\n",
+		"  日本     b\n",
+		"  stop     c\n",
+	} {
+		if !strings.Contains(help, line) {
+			t.Errorf("HelpStr is missing %q:\n%s", line, help)
+		}
+	}
+}
+
+func TestHelpOptionGroups(t *testing.T) {
+	cmd := Command{
+		Name: "deploy",
+		Options: &[]Option{
+			{Long: "cluster", Type: "string", Group: "Targeting"},
+			{Long: "dry-run", Type: "bool"},
+			{Long: "region", Type: "string", Group: "Targeting"},
+			{Long: "token", Type: "string", Group: "Auth"},
+		},
+	}
+	ctx := helpContext(&cmd, "deploy", nil, nil)
+	data := ctx.HelpData()
+
+	names := []string{}
+	for _, group := range data.OptionGroups {
+		options := []string{}
+		for _, option := range group.Options {
+			options = append(options, option.Long)
+		}
+		names = append(names, group.Name+": "+strings.Join(options, ","))
+	}
+	expected := "Options: dry-run,help|Targeting: cluster,region|Auth: token"
+	if strings.Join(names, "|") != expected {
+		t.Errorf("HelpData grouped the options as %s. Expected %s", strings.Join(names, "|"), expected)
+	}
+}
+
+func TestHelpTemplate(t *testing.T) {
+	root := Command{Name: "root"}
+	cli := NewCli(&root)
+
+	if err := cli.SetHelpTemplate("{{.Usage"); err == nil {
+		t.Errorf("SetHelpTemplate did not return an error for an invalid template")
+	}
+	if err := cli.SetHelpTemplate("USAGE\n  {{.Usage}}\n{{range .GlobalOptions}}{{.Name}}{{end}}"); err != nil {
+		t.Errorf("SetHelpTemplate returned an error: %s", err)
+	}
+
+	ctx := helpContext(&root, "root", nil, &cli)
+	if help := ctx.HelpStr(); help != "USAGE"+Sep()+"  root [OPTIONS]"+Sep()+"-h,--help" {
+		t.Errorf("HelpStr did not use the CLI's template: %q", help)
+	}
+
+	// the command's template takes precedence
+	root.HelpTemplate = "{{.Referrer}} help"
+	if help := ctx.HelpStr(); help != "root help" {
+		t.Errorf("HelpStr did not use the command's template: %q", help)
+	}
+}
+
+// Returns what f writes to a file such as os.Stdout
+func capture(t *testing.T, file **os.File, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *file
+	*file = w
+	defer func() { *file = original }()
+
+	f()
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+// Returns what f prints to stdout
+func captureStdout(t *testing.T, f func()) string {
+	return capture(t, &os.Stdout, f)
+}
+
+// Returns what f prints to stderr
+func captureStderr(t *testing.T, f func()) string {
+	return capture(t, &os.Stderr, f)
+}
+
+func TestWantsHelp(t *testing.T) {
+	cmd := Command{
+		Name: "run",
+		Options: &[]Option{
+			{Short: "l", Long: "label", Type: "string"},
+			{Short: "v", Type: "bool"},
+		},
+	}
+
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{"--help"}, true},
+		{[]string{"-h"}, true},
+		{[]string{"-v", "dir", "-h"}, true},
+		{[]string{"--label", "--help"}, false},
+		{[]string{"-l", "-h", "dir"}, false},
+		{[]string{"--label=x", "--help"}, true},
+		{[]string{"--", "--help"}, false},
+		{[]string{"dir"}, false},
+	}
+	for _, test := range tests {
+		if wantsHelp(&cmd, test.args) != test.expected {
+			t.Errorf("wantsHelp(%q) returned %t", test.args, !test.expected)
+		}
+	}
+
+	// a command can use "-h" for something else
+	cmd.Options = &[]Option{{Short: "h", Long: "host", Type: "bool"}}
+	if wantsHelp(&cmd, []string{"-h"}) || !wantsHelp(&cmd, []string{"--help"}) {
+		t.Errorf("wantsHelp did not leave '-h' to the command's option")
+	}
+}
+
+func TestHelpCommand(t *testing.T) {
+	setTerminalWidth(t, 200)
+
+	root := Command{Name: "root", ShortDesc: "The root"}
+	db := Command{Name: "db", ShortDesc: "Manage databases"}
+	migrate := Command{Name: "migrate", ShortDesc: "Run the migrations", Behavior: func(ctx Context) {}}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &db)
+	cli.AddChild(&db, &migrate)
+	cli.addBuiltins()
+
+	var err error
+	out := captureStdout(t, func() { err = cli.run([]string{"help", "db", "migrate"}) })
+	if err != nil || !strings.HasPrefix(out, "Usage: root db migrate [OPTIONS]") {
+		t.Errorf("help printed %q, err: %v", out, err)
+	}
+
+	out = captureStdout(t, func() { err = cli.run([]string{"help"}) })
+	if err != nil || !strings.HasPrefix(out, "Usage: root [COMMAND] [OPTIONS]") {
+		t.Errorf("help printed %q, err: %v", out, err)
+	}
+
+	if err = cli.run([]string{"help", "db", "nope"}); err == nil || err.Error() != "Unknown command 'nope' for 'root db'." {
+		t.Errorf("help did not return an error for an unknown command: %v", err)
+	}
+
+	out = captureStdout(t, func() { err = cli.run([]string{"help", "--all"}) })
+	expected := "root             The root\n" +
+		"  db             Manage databases\n" +
+		"    migrate      Run the migrations\n" +
+		"  completion     Generate a shell completion script\n" +
+		"  help           Print the help string of a command\n"
+	if err != nil || strings.ReplaceAll(out, Sep(), "\n") != expected {
+		t.Errorf("help --all printed\n%s\nExpected\n%s", out, expected)
+	}
+
+	out = captureStdout(t, func() { err = cli.run([]string{"help", "db", "--all"}) })
+	expected = "db            Manage databases\n" +
+		"  migrate     Run the migrations\n"
+	if err != nil || strings.ReplaceAll(out, Sep(), "\n") != expected {
+		t.Errorf("help db --all printed\n%s\nExpected\n%s", out, expected)
+	}
+}
+
+func TestHelpCommandGroups(t *testing.T) {
+	setTerminalWidth(t, 200)
+
+	root := Command{Name: "root"}
+	children := []*Command{
+		{Name: "logs", ShortDesc: "a", Group: "Debugging"},
+		{Name: "scale", ShortDesc: "b", Group: "Cluster Management"},
+		{Name: "version", ShortDesc: "c", Order: 1},
+		{Name: "deploy", ShortDesc: "d", Group: "Cluster Management"},
+		{Name: "config", ShortDesc: "e"},
+		{Name: "exec", ShortDesc: "f", Group: "Debugging", Order: -1},
+	}
+	cli := NewCli(&root)
+	ctx := helpContext(&root, "root", children, &cli)
+
+	expected := "Usage: root [COMMAND] [OPTIONS]\n" +
+		"\n" +
+		"Commands:\n" +
+		"  config      e\n" +
+		"  version     c\n" +
+		"\n" +
+		"Debugging:\n" +
+		"  exec        f\n" +
+		"  logs        a\n" +
+		"\n" +
+		"Cluster Management:\n" +
+		"  scale       b\n" +
+		"  deploy      d\n" +
+		"\n"
+	if help := strings.ReplaceAll(ctx.HelpStr(), Sep(), "\n"); !strings.HasPrefix(help, expected) {
+		t.Errorf("HelpStr returned\n%s\nExpected\n%s", help, expected)
+	}
+
+	cli.SetSortCommands(true)
+	names := []string{}
+	for _, group := range ctx.HelpData().CommandGroups {
+		commands := []string{}
+		for _, cmd := range group.Commands {
+			commands = append(commands, cmd.Name)
+		}
+		names = append(names, group.Name+": "+strings.Join(commands, ","))
+	}
+	expectedNames := "Commands: config,version|Debugging: exec,logs|Cluster Management: deploy,scale"
+	if strings.Join(names, "|") != expectedNames {
+		t.Errorf("HelpData grouped the commands as %s. Expected %s", strings.Join(names, "|"), expectedNames)
+	}
+}
@@ -0,0 +1,246 @@
+package gocli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A command which RunJobs runs
+type Job struct {
+	// Name of the job, shown in the labels of its streamed output and in the
+	// summary
+	Name string
+
+	// The command
+	Cmd string
+
+	// Runner which runs the command instead of JobOptions.Runner
+	Runner *Runner
+
+	// Kill the command if it takes longer than this. No limit if it is 0
+	Timeout time.Duration
+}
+
+// Configuration of RunJobs
+type JobOptions struct {
+	// Maximum number of jobs which run at the same time. No limit if it is 0
+	Parallel int
+
+	// Keep running the other jobs when a job fails. Otherwise the running
+	// jobs are canceled and the jobs which did not start are skipped
+	ContinueOnError bool
+
+	// Stream the output of the jobs, with the name of the job before every
+	// line
+	Stream bool
+
+	// Runner which runs the commands, e.g. with a Dir or Env
+	Runner Runner
+}
+
+// What happened to a job
+type JobStatus string
+
+const (
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+
+	// The job was killed because another job failed or the context of
+	// RunJobs was done
+	JobCanceled JobStatus = "canceled"
+
+	// The job did not start because another job failed or the context of
+	// RunJobs was done
+	JobSkipped JobStatus = "skipped"
+
+	// The task did not run because its outputs were up to date, see Task
+	JobUpToDate JobStatus = "up-to-date"
+)
+
+// The result of a job. BashResult is empty, apart from Cmd and ExitCode (-1),
+// if the job was skipped
+type JobResult struct {
+	Name   string    `json:"name"`
+	Status JobStatus `json:"status"`
+	BashResult
+}
+
+// The results of RunJobs, in the order of the jobs
+type JobResults []JobResult
+
+// Colors of the labels of the jobs, which are assigned in turn
+var jobColors = []func(s string) string{Cyan, Magenta, Yellow, Green, Blue}
+
+// Run commands concurrently, at most options.Parallel at a time, in the order
+// of the jobs. Unless options.ContinueOnError is set, the first failure
+// cancels the running jobs and skips the rest. The jobs which are running
+// when ctx is done are killed, with the processes they started, and the
+// rest are skipped. Returns the results of all jobs, and an error if any of
+// them did not succeed
+func RunJobs(ctx context.Context, jobs []Job, options JobOptions) (JobResults, error) {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	limit := options.Parallel
+	if limit <= 0 || limit > len(jobs) {
+		limit = max(len(jobs), 1)
+	}
+	slots := make(chan struct{}, limit)
+
+	width := 0
+	for _, job := range jobs {
+		width = max(displayWidth(job.Name), width)
+	}
+
+	results := make(JobResults, len(jobs))
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		results[i] = JobResult{Name: job.Name, Status: JobSkipped, BashResult: BashResult{Cmd: job.Cmd, ExitCode: -1}}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, job Job) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			jobCtx := ctx
+			if job.Timeout > 0 {
+				var cancelJob context.CancelFunc
+				jobCtx, cancelJob = context.WithTimeout(ctx, job.Timeout)
+				defer cancelJob()
+			}
+			res := options.jobRunner(job, i, width).RunContext(jobCtx, job.Cmd)
+
+			status := jobStatus(ctx, res)
+			results[i] = JobResult{Name: job.Name, Status: status, BashResult: res}
+
+			if status == JobFailed && !options.ContinueOnError {
+				cancel()
+			}
+		}(i, job)
+	}
+	wg.Wait()
+
+	if failed := results.withStatus(JobFailed); len(failed) > 0 {
+		return results, fmt.Errorf("%d of %d jobs failed.", len(failed), len(results))
+	}
+	if err := parent.Err(); err != nil {
+		return results, contextError(err)
+	}
+	return results, nil
+}
+
+// Returns the status of a job which ran with ctx, the context which is shared
+// by the jobs
+func jobStatus(ctx context.Context, res BashResult) JobStatus {
+	if res.Success() {
+		return JobSucceeded
+	}
+	// killed by the shared context rather than its own timeout
+	if ctx.Err() != nil && (errors.Is(res.Err, context.Canceled) || errors.Is(res.Err, ErrTimeout)) {
+		return JobCanceled
+	}
+	return JobFailed
+}
+
+// Returns the runner of the i-th job. Streamed output is labeled with the
+// name of the job, padded to width
+func (options JobOptions) jobRunner(job Job, i int, width int) Runner {
+	r := options.Runner
+	if job.Runner != nil {
+		r = *job.Runner
+	}
+	if !options.Stream {
+		return r
+	}
+
+	r.StreamStdout, r.StreamStderr = true, true
+	label := paddedName("["+job.Name+"]", width+2) + " "
+	if r.StdoutLabel == "" {
+		r.StdoutLabel = label
+	}
+	if r.StderrLabel == "" {
+		r.StderrLabel = label
+	}
+	color := jobColors[i%len(jobColors)]
+	if r.StdoutColor == nil {
+		r.StdoutColor = color
+	}
+	if r.StderrColor == nil {
+		r.StderrColor = color
+	}
+	return r
+}
+
+// Returns the results with the status
+func (results JobResults) withStatus(status JobStatus) JobResults {
+	matching := JobResults{}
+	for _, res := range results {
+		if res.Status == status {
+			matching = append(matching, res)
+		}
+	}
+	return matching
+}
+
+// Returns the jobs which failed
+func (results JobResults) Failed() JobResults {
+	return results.withStatus(JobFailed)
+}
+
+// Returns true if every job succeeded (or was up to date)
+func (results JobResults) Success() bool {
+	return len(results.withStatus(JobSucceeded))+len(results.withStatus(JobUpToDate)) == len(results)
+}
+
+// Returns a table of the results, e.g.
+//
+//	JOB     STATUS     EXIT  DURATION
+//	build   succeeded  0     1.204s
+//	test    failed     2     3.51s
+//	deploy  skipped    -     -
+func (results JobResults) Summary() string {
+	rows := [][]string{{"JOB", "STATUS", "EXIT", "DURATION"}}
+	for _, res := range results {
+		exit, duration := "-", "-"
+		if res.Status != JobSkipped && res.Status != JobUpToDate {
+			duration = res.Duration.Round(time.Millisecond).String()
+			if res.ExitCode >= 0 {
+				exit = strconv.Itoa(res.ExitCode)
+			} else if res.Signal != "" {
+				exit = res.Signal
+			}
+		}
+		rows = append(rows, []string{res.Name, string(res.Status), exit, duration})
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(displayWidth(cell), widths[i])
+		}
+	}
+
+	lines := []string{}
+	for _, row := range rows {
+		line := ""
+		for i, cell := range row {
+			line += paddedName(cell, widths[i]+2)
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	return strings.Join(lines, Sep())
+}
@@ -0,0 +1,127 @@
+package gocli
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func jobStatuses(results JobResults) string {
+	statuses := []string{}
+	for _, res := range results {
+		statuses = append(statuses, res.Name+":"+string(res.Status))
+	}
+	return strings.Join(statuses, " ")
+}
+
+func TestRunJobs(t *testing.T) {
+	results, err := RunJobs(context.Background(), []Job{
+		{Name: "a", Cmd: "echo a"},
+		{Name: "b", Cmd: "echo b; exit 2"},
+		{Name: "c", Cmd: "echo c"},
+	}, JobOptions{ContinueOnError: true})
+
+	if err == nil || err.Error() != "1 of 3 jobs failed." {
+		t.Errorf("RunJobs returned the error %v", err)
+	}
+	if jobStatuses(results) != "a:succeeded b:failed c:succeeded" {
+		t.Errorf("RunJobs returned %s", jobStatuses(results))
+	}
+	if results[1].ExitCode != 2 || results[2].Stdout != "c\n" || results.Success() || len(results.Failed()) != 1 {
+		t.Errorf("RunJobs returned %+v", results)
+	}
+
+	results, err = RunJobs(context.Background(), []Job{{Name: "a", Cmd: "true"}}, JobOptions{})
+	if err != nil || !results.Success() {
+		t.Errorf("RunJobs returned %+v, %v", results, err)
+	}
+}
+
+func TestRunJobsParallel(t *testing.T) {
+	// each job takes 200ms, so 4 jobs take 400ms with 2 slots, rather than
+	// 200ms without a limit or 800ms one by one
+	jobs := []Job{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		jobs = append(jobs, Job{Name: name, Cmd: "sleep 0.2"})
+	}
+
+	start := time.Now()
+	results, err := RunJobs(context.Background(), jobs, JobOptions{Parallel: 2})
+	elapsed := time.Since(start)
+
+	if err != nil || !results.Success() {
+		t.Fatalf("RunJobs returned %+v, %v", results, err)
+	}
+	if elapsed < 400*time.Millisecond || elapsed >= 800*time.Millisecond {
+		t.Errorf("4 jobs of 200ms with 2 slots took %s", elapsed)
+	}
+}
+
+func TestRunJobsFailFast(t *testing.T) {
+	start := time.Now()
+	results, err := RunJobs(context.Background(), []Job{
+		{Name: "slow", Cmd: "sleep 10"},
+		{Name: "fail", Cmd: "exit 1"},
+		{Name: "later", Cmd: "echo later"},
+	}, JobOptions{Parallel: 2})
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the failure did not cancel the running job, RunJobs took %s", elapsed)
+	}
+	if err == nil || jobStatuses(results) != "slow:canceled fail:failed later:skipped" {
+		t.Errorf("RunJobs returned %s, %v", jobStatuses(results), err)
+	}
+}
+
+func TestRunJobsCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+	}()
+	results, err := RunJobs(ctx, []Job{
+		{Name: "a", Cmd: "sleep 10"},
+		{Name: "b", Cmd: "sleep 10"},
+	}, JobOptions{Parallel: 1})
+
+	if !errors.Is(err, context.Canceled) || jobStatuses(results) != "a:canceled b:skipped" {
+		t.Errorf("RunJobs returned %s, %v", jobStatuses(results), err)
+	}
+
+	// a job's own timeout is a failure
+	results, _ = RunJobs(context.Background(), []Job{{Name: "a", Cmd: "sleep 10", Timeout: 100 * time.Millisecond}}, JobOptions{})
+	if jobStatuses(results) != "a:failed" || !errors.Is(results[0].Err, ErrTimeout) {
+		t.Errorf("RunJobs returned %s, %v", jobStatuses(results), results[0].Err)
+	}
+}
+
+func TestRunJobsStream(t *testing.T) {
+	out := captureStdout(t, func() {
+		RunJobs(context.Background(), []Job{
+			{Name: "api", Cmd: "echo one"},
+			{Name: "worker", Cmd: "sleep 0.1; echo two"},
+		}, JobOptions{Stream: true})
+	})
+	if out != "[api]    one"+Sep()+"[worker] two"+Sep() {
+		t.Errorf("RunJobs streamed %q", out)
+	}
+}
+
+func TestJobResultsSummary(t *testing.T) {
+	results := JobResults{
+		{Name: "build", Status: JobSucceeded, BashResult: BashResult{ExitCode: 0, Duration: 1204 * time.Millisecond}},
+		{Name: "test", Status: JobCanceled, BashResult: BashResult{ExitCode: -1, Signal: "SIGKILL", Duration: 3510 * time.Millisecond}},
+		{Name: "deploy", Status: JobSkipped, BashResult: BashResult{ExitCode: -1}},
+	}
+	expected := strings.Join([]string{
+		"JOB     STATUS     EXIT     DURATION",
+		"build   succeeded  0        1.204s",
+		"test    canceled   SIGKILL  3.51s",
+		"deploy  skipped    -        -",
+	}, Sep())
+	if summary := results.Summary(); summary != expected {
+		t.Errorf("Summary returned\n%s", summary)
+	}
+}
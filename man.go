@@ -0,0 +1,194 @@
+package gocli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Header of the generated man pages
+type ManHeader struct {
+	// Section of the manual. Defaults to "1"
+	Section string
+
+	// Shown in the center of the footer, e.g. "Oct 2026". Left empty by
+	// default so that the pages do not change between builds
+	Date string
+
+	// Shown in the left of the footer, e.g. "example 1.2.0"
+	Source string
+
+	// Shown in the center of the header, e.g. "Example Manual"
+	Manual string
+}
+
+// Escape text for roff
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+
+	// lines starting with a "." or "'" are roff requests
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Escape text for a quoted argument of a roff macro, e.g. .SH "TITLE". The
+// argument has to stay on one line and a quote would end it
+func roffQuote(s string) string {
+	s = roffEscape(strings.Join(strings.Fields(s), " "))
+	return strings.ReplaceAll(s, `"`, `\(dq`)
+}
+
+// Name of the man page of a command, e.g. "example-run"
+func manName(path []string) string {
+	return strings.Join(path, "-")
+}
+
+// Write the man page of a command to w
+func (cli *Cli) GenMan(cmd *Command, header ManHeader, w io.Writer) error {
+	path := cli.commandPath(cmd)
+	if path == nil {
+		return fmt.Errorf("Command \"%s\" is not in the CLI tree.", cmd.Name)
+	}
+	if header.Section == "" {
+		header.Section = "1"
+	}
+	children := cli.listedChildren(cmd)
+	options := cli.options(cmd)
+	name := manName(path)
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, ".TH \"%s\" \"%s\" \"%s\" \"%s\" \"%s\"\n", roffQuote(strings.ToUpper(name)), roffQuote(header.Section),
+		roffQuote(header.Date), roffQuote(header.Source), roffQuote(header.Manual))
+	b.WriteString(".nh\n.ad l\n")
+
+	b.WriteString(".SH NAME\n")
+	b.WriteString(roffEscape(name))
+	if desc := cmd.ShortDesc; desc != "" {
+		b.WriteString(` \- ` + roffEscape(desc))
+	}
+	b.WriteString("\n")
+
+	b.WriteString(".SH SYNOPSIS\n")
+	fmt.Fprintf(b, "\\fB%s\\fP%s\n", roffEscape(strings.Join(path, " ")), roffEscape(usageSuffix(cmd, children, options)))
+
+	if desc := cmd.LongDesc; desc != "" {
+		b.WriteString(".SH DESCRIPTION\n")
+		b.WriteString(roffEscape(desc) + "\n")
+	}
+
+	if len(children) > 0 {
+		b.WriteString(".SH COMMANDS\n")
+		for _, child := range children {
+			fmt.Fprintf(b, ".TP\n\\fB%s\\fP\n%s\n", roffEscape(child.Name), roffEscape(child.ShortDesc))
+		}
+	}
+
+	if len(options) > 0 {
+		b.WriteString(".SH OPTIONS\n")
+		for _, opt := range visibleOptions(options) {
+			flags := []string{}
+			for _, flag := range optionFlags(opt) {
+				flags = append(flags, `\fB`+roffEscape(flag)+`\fP`)
+			}
+			b.WriteString(".TP\n" + strings.Join(flags, ", "))
+			if opt.takesValue() {
+				fmt.Fprintf(b, " \\fI%s\\fP", roffEscape(opt.Type))
+			}
+			b.WriteString("\n")
+			if opt.Required {
+				b.WriteString("(Required) ")
+			}
+			b.WriteString(roffEscape(opt.Description) + "\n")
+		}
+	}
+
+	if arg := cmd.Argument; arg.Name != "" {
+		b.WriteString(".SH ARGUMENTS\n")
+		fmt.Fprintf(b, ".TP\n\\fI%s\\fP\n", roffEscape(arg.Name))
+		if arg.Required {
+			b.WriteString("(Required) ")
+		}
+		b.WriteString(roffEscape(arg.Description) + "\n")
+	}
+
+	if len(cmd.Examples) > 0 {
+		b.WriteString(".SH EXAMPLES\n")
+		for _, example := range cmd.Examples {
+			if example.Description != "" {
+				b.WriteString(".PP\n" + roffEscape(example.Description) + "\n")
+			}
+			b.WriteString(".PP\n.RS\n.nf\n" + roffEscape(example.Command) + "\n.fi\n.RE\n")
+		}
+	}
+
+	for _, section := range cmd.Sections {
+		fmt.Fprintf(b, ".SH \"%s\"\n", roffQuote(strings.ToUpper(section.Title)))
+		b.WriteString(roffEscape(section.Body) + "\n")
+	}
+
+	// link the parent, the children and the related commands
+	seeAlso := []string{}
+	if len(path) > 1 {
+		seeAlso = append(seeAlso, manName(path[:len(path)-1]))
+	}
+	for _, child := range children {
+		seeAlso = append(seeAlso, manName(append(append([]string{}, path...), child.Name)))
+	}
+	for _, related := range cmd.SeeAlso {
+		seeAlso = append(seeAlso, manName(strings.Fields(related)))
+	}
+	if len(seeAlso) > 0 {
+		b.WriteString(".SH SEE ALSO\n")
+		refs := []string{}
+		for _, ref := range seeAlso {
+			refs = append(refs, fmt.Sprintf("\\fB%s\\fP(%s)", roffEscape(ref), header.Section))
+		}
+		b.WriteString(strings.Join(refs, ", ") + "\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Write a man page for every command of the CLI into dir. The pages are
+// named after the command path, e.g. "example-run.1"
+func (cli *Cli) GenManTree(dir string, header ManHeader) error {
+	cli = cli.withBuiltins()
+	if header.Section == "" {
+		header.Section = "1"
+	}
+
+	var err error
+	cli.walkListed(func(cmd *Command, parents []string) {
+		if err != nil {
+			return
+		}
+		filename := filepath.Join(dir, manName(append(parents, cmd.Name))+"."+header.Section)
+		err = writeFile(filename, func(w io.Writer) error {
+			return cli.GenMan(cmd, header, w)
+		})
+	})
+	return err
+}
+
+// Create the file and write it with "write". Returns the first error, including
+// the one of closing the file, which may be the first to report a failed write
+func writeFile(filename string, write func(w io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
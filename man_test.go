@@ -0,0 +1,96 @@
+package gocli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenMan(t *testing.T) {
+	root := Command{Name: "root", LongDesc: "The root command"}
+	run := Command{
+		Name:      "run",
+		ShortDesc: "Run it",
+		LongDesc:  "Run it.\n.dangerous line",
+		Options: &[]Option{
+			{Short: "l", Long: "label", Type: "string", Description: "Label", Required: true},
+		},
+		Argument: Argument{Name: "target", Description: "Where to run"},
+	}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &run)
+
+	b := &strings.Builder{}
+	if err := cli.GenMan(&run, ManHeader{Source: "root 1.0"}, b); err != nil {
+		t.Errorf("GenMan returned an error: %s", err)
+	}
+
+	expected := []string{
+		`.TH "ROOT\-RUN" "1" "" "root 1.0" ""`,
+		".SH NAME\nroot\\-run \\- Run it\n",
+		".SH SYNOPSIS\n\\fBroot run\\fP [OPTIONS] target\n",
+		".SH DESCRIPTION\nRun it.\n\\&.dangerous line\n",
+		".TP\n\\fB\\-l\\fP, \\fB\\-\\-label\\fP \\fIstring\\fP\n(Required) Label\n",
+		".SH ARGUMENTS\n.TP\n\\fItarget\\fP\nWhere to run\n",
+		".SH SEE ALSO\n\\fBroot\\fP(1)\n",
+	}
+	for _, part := range expected {
+		if !strings.Contains(b.String(), part) {
+			t.Errorf("man page is missing %q:\n%s", part, b.String())
+		}
+	}
+
+	// quotes would end the arguments of the macros
+	run.Sections = []Section{{Title: `The "env" file`, Body: "Read first."}}
+	b.Reset()
+	if err := cli.GenMan(&run, ManHeader{Source: `root "1.0"`}, b); err != nil {
+		t.Errorf("GenMan returned an error: %s", err)
+	}
+	for _, part := range []string{`.TH "ROOT\-RUN" "1" "" "root \(dq1.0\(dq" ""`, `.SH "THE \(dqENV\(dq FILE"`} {
+		if !strings.Contains(b.String(), part) {
+			t.Errorf("man page is missing %q:\n%s", part, b.String())
+		}
+	}
+
+	if err := cli.GenMan(&Command{Name: "other"}, ManHeader{}, b); err == nil {
+		t.Errorf("GenMan did not return an error for a command outside the tree")
+	}
+}
+
+func TestGenManTree(t *testing.T) {
+	root := Command{Name: "root"}
+	run := Command{Name: "run"}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &run)
+
+	dir := t.TempDir()
+	if err := cli.GenManTree(dir, ManHeader{Section: "8"}); err != nil {
+		t.Errorf("GenManTree returned an error: %s", err)
+	}
+
+	for _, name := range []string{"root.8", "root-run.8", "root-completion.8"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("GenManTree did not write %s: %s", name, err)
+			continue
+		}
+		if !strings.HasPrefix(string(content), ".TH ") {
+			t.Errorf("%s is not a man page:\n%s", name, content)
+		}
+	}
+
+	content, _ := os.ReadFile(filepath.Join(dir, "root.8"))
+	if !strings.Contains(string(content), `\fBroot\-run\fP(8), \fBroot\-completion\fP(8)`) {
+		t.Errorf("root.8 does not link its children:\n%s", content)
+	}
+
+	// the built-in commands are documented without being added to the CLI
+	if len(cli.childrenMap[&root]) != 1 {
+		t.Errorf("GenManTree changed the children of the root: %v", cli.childrenMap[&root])
+	}
+
+	if err := cli.GenManTree(filepath.Join(dir, "missing"), ManHeader{}); err == nil {
+		t.Errorf("GenManTree did not return an error for a missing directory")
+	}
+}
@@ -1,6 +1,10 @@
 package gocli
 
-import "strings"
+import (
+	"os"
+	"strconv"
+	"strings"
+)
 
 type Option struct {
 	// A short description of the option
@@ -17,6 +21,26 @@ type Option struct {
 
 	// Type of the option: "string", "bool", or "float", "int"
 	Type string
+
+	// Heading under which the option is listed in the help string. Options
+	// without a group are listed under "Options"
+	Group string
+
+	// Returns the candidates for the option's value during shell completion,
+	// see CompleteWith. A pointer keeps Option comparable
+	Complete *CompletionFunc
+
+	// Leave the option out of the help string, the documentation and the
+	// completions. It can still be used
+	Hidden bool
+
+	// If set, using the option prints a warning followed by this message,
+	// which should name the replacement, e.g. "Use --cluster instead."
+	Deprecated string
+
+	// The option can only be used if experimental features are enabled, see
+	// ExperimentalEnv
+	Experimental bool
 }
 
 func (o *Option) Name() string {
@@ -32,15 +56,115 @@ func (o *Option) Name() string {
 	return ""
 }
 
+// Returns the options of a command, including the default options. The
+// command's own options take precedence: a default option loses the names
+// which the command already uses, and is left out if it has none left
+func commandOptions(c *Command) []Option {
+	own := []Option{}
+	if c.Options != nil {
+		own = *c.Options
+	}
+	options := append([]Option{}, own...)
+
+	for _, def := range DefaultOptions {
+		if def = unclaimed(def, own); def.Name() != "" {
+			options = append(options, def)
+		}
+	}
+	return options
+}
+
+// Remove the names of a default option which are used by other options
+func unclaimed(def Option, options []Option) Option {
+	if _, ok := matchShort(def.Short, options); ok && def.Short != "" {
+		def.Short = ""
+	}
+	if _, ok := matchLong(def.Long, options); ok && def.Long != "" {
+		def.Long = ""
+	}
+	return def
+}
+
+// Returns true if the args ask for the help string of the command, i.e. they
+// contain a flag of HelpOption. Values of other options and the args after
+// "--" are not considered
+func wantsHelp(c *Command, args []string) bool {
+	return hasDefaultFlag(c, HelpOption, commandOptions(c), args)
+}
+
+// Returns true if the args contain a flag of the default option "def" which
+// the command did not claim for one of its own options. Values of the
+// "options" and the args after "--" are not considered
+func hasDefaultFlag(c *Command, def Option, options []Option, args []string) bool {
+	own := []Option{}
+	if c.Options != nil {
+		own = *c.Options
+	}
+	flags := optionFlags(unclaimed(def, own))
+
+	// the same tokens as ParseArgs, so the value of an option is skipped,
+	// e.g. "--help" in "--label --help"
+	for _, tok := range tokenize(options, args) {
+		if tok.kind != flagToken {
+			continue
+		}
+		for _, flag := range flags {
+			if tok.arg == flag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns true if the option expects a value (i.e. is not a boolean flag)
+func (o *Option) takesValue() bool {
+	return o.Type != "bool"
+}
+
 // default options
 var HelpOption = Option{
 	Description: "Print a help string",
-	Short:       "",
+	Short:       "h",
 	Long:        "help",
 	Required:    false,
 	Type:        "bool",
 }
 
+var ExperimentalOption = Option{
+	Description: "Enable experimental commands and options",
+	Long:        "experimental",
+	Required:    false,
+	Type:        "bool",
+	Hidden:      true,
+}
+
 var DefaultOptions = []Option{
 	HelpOption,
+	ExperimentalOption,
+}
+
+// Environment variable which enables the experimental commands and options
+// when set to a true value, e.g. GOCLI_EXPERIMENTAL=1. They can also be
+// enabled with "--experimental"
+var ExperimentalEnv = "GOCLI_EXPERIMENTAL"
+
+// Returns true if experimental features are enabled by the environment or by
+// the parsed args
+func experimentalEnabled(args map[string]interface{}) bool {
+	if enabled, err := strconv.ParseBool(os.Getenv(ExperimentalEnv)); err == nil && enabled {
+		return true
+	}
+	return args[ExperimentalOption.Long] == true
+}
+
+// Returns the options which are not hidden
+func visibleOptions(options []Option) []Option {
+	visible := []Option{}
+	for _, opt := range options {
+		if !opt.Hidden {
+			visible = append(visible, opt)
+		}
+	}
+	return visible
 }
@@ -0,0 +1,160 @@
+package gocli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// An executable which extends the CLI, e.g. "example-deploy" on the PATH runs
+// as "example deploy"
+type Plugin struct {
+	// Name of the plugin below its command, e.g. "deploy"
+	Name string
+
+	// Path of the executable
+	Path string
+}
+
+// Run executables named "<root>-<name>" when no command matches "<name>",
+// like git runs "git-foo" for "git foo". Plugins of sub-commands are named
+// after the full path, e.g. "example-db-migrate" for "example db migrate".
+// The executables are looked up in dirs, then on the PATH
+func (cli *Cli) EnablePlugins(dirs ...string) {
+	cli.plugins = true
+	cli.pluginDirs = dirs
+}
+
+// Returns the directories which are searched for plugins, in order
+func (cli *Cli) pluginSearchPath() []string {
+	return append(append([]string{}, cli.pluginDirs...), filepath.SplitList(os.Getenv("PATH"))...)
+}
+
+// Returns the name of an executable without its extension on Windows, or
+// false if the file is not executable
+func executableName(path string, info os.FileInfo) (string, bool) {
+	if info.IsDir() {
+		return "", false
+	}
+	name := filepath.Base(path)
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(name))
+		for _, executable := range []string{".exe", ".bat", ".cmd", ".com"} {
+			if ext == executable {
+				return strings.TrimSuffix(name, filepath.Ext(name)), true
+			}
+		}
+		return "", false
+	}
+	return name, info.Mode()&0111 != 0
+}
+
+// Returns the plugins of the command at path, sorted by name. Plugins which
+// have the name of a child command, or belong to one (e.g. "db-migrate" when
+// "db" is a child), are left out
+func (cli *Cli) findPlugins(path []string, children []*Command) []Plugin {
+	prefix := strings.Join(path, "-") + "-"
+	found := map[string]Plugin{}
+
+	for _, dir := range cli.pluginSearchPath() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), prefix) {
+				continue
+			}
+			file := filepath.Join(dir, entry.Name())
+			info, err := os.Stat(file)
+			if err != nil {
+				continue
+			}
+			name, ok := executableName(file, info)
+			if !ok || len(name) == len(prefix) {
+				continue
+			}
+			name = name[len(prefix):]
+			if _, ok := found[name]; !ok {
+				found[name] = Plugin{Name: name, Path: file}
+			}
+		}
+	}
+
+	plugins := []Plugin{}
+	for name, plugin := range found {
+		taken := false
+		for _, child := range children {
+			first := strings.SplitN(name, "-", 2)[0]
+			taken = taken || child.hasName(name) || child.hasName(first)
+		}
+		if !taken {
+			plugins = append(plugins, plugin)
+		}
+	}
+	sort.Slice(plugins, func(i int, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+	return plugins
+}
+
+// Find the plugin which the words refer to below the command at path.
+// Longer names are preferred, e.g. "db migrate" runs "example-db-migrate"
+// before "example-db". Returns the plugin and the words which follow it
+func (cli *Cli) lookupPlugin(path []string, words []string) (Plugin, []string, bool) {
+	n := 0
+	for n < len(words) && words[n] != "" && !strings.HasPrefix(words[n], "-") {
+		n++
+	}
+
+	for ; n > 0; n-- {
+		name := strings.Join(append(append([]string{}, path...), words[:n]...), "-")
+		for _, dir := range cli.pluginSearchPath() {
+			if dir == "" {
+				continue
+			}
+			if file, err := exec.LookPath(filepath.Join(dir, name)); err == nil {
+				return Plugin{Name: strings.Join(words[:n], "-"), Path: file}, words[n:], true
+			}
+		}
+	}
+	return Plugin{}, words, false
+}
+
+// Run a plugin of the command at path with args. The plugin inherits the
+// standard streams and the environment, plus
+//
+//	GOCLI_ROOT        the name of the root command, e.g. "example"
+//	GOCLI_COMMAND     the full name of the command, e.g. "example db"
+//	GOCLI_PLUGIN      the name of the plugin's executable, e.g. "example-db-migrate"
+//	GOCLI_EXECUTABLE  the path of the CLI's executable
+func (cli *Cli) runPlugin(plugin Plugin, path []string, args []string) error {
+	executable, _ := os.Executable()
+
+	cmd := exec.Command(plugin.Path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"GOCLI_ROOT="+cli.Entrypoint.Name,
+		"GOCLI_COMMAND="+strings.Join(path, " "),
+		"GOCLI_PLUGIN="+strings.Join(path, "-")+"-"+plugin.Name,
+		"GOCLI_EXECUTABLE="+executable,
+	)
+
+	err := cmd.Run()
+	// the plugin printed its own errors, the CLI only passes on its exit code
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return exitCode(exitErr.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("Plugin '%s' failed: %s", plugin.Path, err)
+	}
+	return nil
+}
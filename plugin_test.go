@@ -0,0 +1,126 @@
+package gocli
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Write an executable shell script into dir
+func writePlugin(t *testing.T, dir string, name string, script string) {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func pluginCli(t *testing.T) (Cli, string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	writePlugin(t, dir, "root-hello", `echo "$GOCLI_COMMAND|$GOCLI_PLUGIN|$*" > `+out+"\n")
+	writePlugin(t, dir, "root-db-migrate", `echo "migrate|$GOCLI_COMMAND|$*" > `+out+"\n")
+	writePlugin(t, dir, "root-fail", "exit 3\n")
+	writePlugin(t, dir, "root-run", "exit 0\n")
+	if err := os.WriteFile(filepath.Join(dir, "root-notes"), []byte("not executable"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root := Command{Name: "root"}
+	db := Command{Name: "db", ShortDesc: "Manage databases"}
+	run := Command{Name: "run", ShortDesc: "Run it", Behavior: func(ctx Context) {}}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &db)
+	cli.AddChild(&root, &run)
+	cli.EnablePlugins(dir)
+	return cli, out
+}
+
+func TestRunPlugin(t *testing.T) {
+	cli, out := pluginCli(t)
+
+	readOut := func() string {
+		b, _ := os.ReadFile(out)
+		os.Remove(out)
+		return strings.TrimSpace(string(b))
+	}
+
+	if err := cli.run([]string{"hello", "a", "--b"}); err != nil {
+		t.Errorf("cli.run returned an error: %s", err)
+	}
+	if s := readOut(); s != "root|root-hello|a --b" {
+		t.Errorf("plugin received %q", s)
+	}
+
+	// plugins of sub-commands
+	if err := cli.run([]string{"db", "migrate", "up"}); err != nil {
+		t.Errorf("cli.run returned an error: %s", err)
+	}
+	if s := readOut(); s != "migrate|root db|up" {
+		t.Errorf("plugin received %q", s)
+	}
+
+	// the plugin's exit code is passed on
+	var code exitCode
+	if err := cli.run([]string{"fail"}); !errors.As(err, &code) || code != 3 {
+		t.Errorf("cli.run did not return the plugin's exit code: %v", err)
+	}
+}
+
+func TestExecPluginExitCode(t *testing.T) {
+	// Exec exits the process, so it runs in a child process of the test
+	if os.Getenv("GOCLI_TEST_EXEC") == "1" {
+		cli, _ := pluginCli(t)
+		os.Args = []string{"root", "fail"}
+		cli.Exec()
+		return
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestExecPluginExitCode$")
+	cmd.Env = append(os.Environ(), "GOCLI_TEST_EXEC=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("Exec exited with %v", err)
+	}
+	if strings.Contains(string(out), "failed") {
+		t.Errorf("Exec printed\n%s", out)
+	}
+}
+
+func TestFindPlugins(t *testing.T) {
+	cli, _ := pluginCli(t)
+	t.Setenv("PATH", "")
+
+	// commands take precedence, and files which are not executable are skipped
+	names := []string{}
+	for _, plugin := range cli.findPlugins([]string{"root"}, cli.childrenMap[cli.Entrypoint]) {
+		names = append(names, plugin.Name)
+	}
+	if strings.Join(names, ",") != "fail,hello" {
+		t.Errorf("findPlugins returned %v", names)
+	}
+
+	setTerminalWidth(t, 200)
+	ctx := cli.context(cli.Entrypoint, []string{})
+	help := strings.ReplaceAll(ctx.HelpStr(), Sep(), "\n")
+	if !strings.Contains(help, "\nPlugins:\n  fail      ") || !strings.Contains(help, "  hello     ") {
+		t.Errorf("HelpStr did not list the plugins:\n%s", help)
+	}
+
+	db, parents, _ := cli.route([]string{"db"})
+	ctx = cli.context(db, parents)
+	help = strings.ReplaceAll(ctx.HelpStr(), Sep(), "\n")
+	if !strings.Contains(help, "\nPlugins:\n  migrate     ") {
+		t.Errorf("HelpStr did not list the plugins of a sub-command:\n%s", help)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package gocli
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Process groups are not supported on this platform
+func setProcessGroup(c *exec.Cmd) {}
+
+// Kill the command. The processes it started are not killed on this platform
+func killProcessGroup(c *exec.Cmd) error {
+	return c.Process.Kill()
+}
+
+// Signals are not reported on this platform
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
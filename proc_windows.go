@@ -0,0 +1,26 @@
+//go:build windows
+// +build windows
+
+package gocli
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// Processes are killed as a tree on Windows, see killProcessGroup
+func setProcessGroup(c *exec.Cmd) {}
+
+// Kill the command and the processes it started
+func killProcessGroup(c *exec.Cmd) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(c.Process.Pid)).Run(); err != nil {
+		return c.Process.Kill()
+	}
+	return nil
+}
+
+// Processes are not killed by signals on Windows
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
@@ -0,0 +1,94 @@
+package gocli
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Characters which need no quoting in bash and POSIX sh
+var unquoted = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Quote a word for bash or POSIX sh, so that the shell passes it to the
+// command as it is, e.g. Quote("my dir") is "'my dir'". Words which need no
+// quoting are returned as they are
+func Quote(word string) string {
+	if word == "" {
+		return "''"
+	}
+	if unquoted.MatchString(word) {
+		return word
+	}
+	// a single quote ends the quoted word, is escaped, and starts a new one
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// Quote the words and join them with spaces, e.g.
+// QuoteArgs("rm", "-r", "my dir") is "rm -r 'my dir'"
+func QuoteArgs(words ...string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = Quote(word)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// A shell command which is built from words, so that they need no quoting.
+// Commands are composed into pipelines and chains, e.g.
+//
+//	NewShellCommand("cd", dir).And(NewShellCommand("grep", "-r", pattern).Pipe(NewShellCommand("wc", "-l")))
+//
+// is "cd 'my dir' && grep -r 'a b' | wc -l" and runs with Bash(cmd.String())
+type ShellCommand struct {
+	script string
+
+	// The script is an "&&" or "||" chain, which has to be grouped to be
+	// part of a pipeline
+	chain bool
+}
+
+// Returns the command which runs the executable with the args
+func NewShellCommand(name string, args ...string) ShellCommand {
+	return ShellCommand{script: QuoteArgs(append([]string{name}, args...)...)}
+}
+
+// Returns the command with more args
+func (c ShellCommand) Arg(args ...string) ShellCommand {
+	if len(args) > 0 {
+		c.script += " " + QuoteArgs(args...)
+	}
+	return c
+}
+
+// Returns the pipeline which passes the stdout of c to next
+func (c ShellCommand) Pipe(next ShellCommand) ShellCommand {
+	return ShellCommand{script: c.grouped() + " | " + next.grouped()}
+}
+
+// Returns the chain which runs next if c succeeds
+func (c ShellCommand) And(next ShellCommand) ShellCommand {
+	return c.join("&&", next)
+}
+
+// Returns the chain which runs next if c fails
+func (c ShellCommand) Or(next ShellCommand) ShellCommand {
+	return c.join("||", next)
+}
+
+// Chain the commands. "&&" and "||" group from the left, so only a chain on
+// the right needs to be grouped
+func (c ShellCommand) join(operator string, next ShellCommand) ShellCommand {
+	return ShellCommand{script: c.script + " " + operator + " " + next.grouped(), chain: true}
+}
+
+// Returns the script, grouped with braces if it is a chain
+func (c ShellCommand) grouped() string {
+	if c.chain {
+		return "{ " + c.script + "; }"
+	}
+	return c.script
+}
+
+// Returns the script of the command
+func (c ShellCommand) String() string {
+	return c.script
+}
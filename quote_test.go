@@ -0,0 +1,90 @@
+package gocli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	for word, expected := range map[string]string{
+		"":            "''",
+		"plain":       "plain",
+		"a/b.c-d_e=f": "a/b.c-d_e=f",
+		"my dir":      "'my dir'",
+		"it's":        `'it'\''s'`,
+		"$HOME":       "'$HOME'",
+	} {
+		if quoted := Quote(word); quoted != expected {
+			t.Errorf("Quote(%q) returned %q", word, quoted)
+		}
+	}
+
+	// the shell passes the quoted words as they are
+	words := []string{"", "my dir", "it's", `"double"`, "$HOME", "`id`", "$(id)", "*", "a;b", "a\nb", "back\\slash", "!x", "~", "ünï"}
+	for _, shell := range []string{ShellBash, ShellSh} {
+		res := Runner{Shell: shell}.Run("printf '%s\\0' " + QuoteArgs(words...))
+		if got := strings.Split(strings.TrimSuffix(res.Stdout, "\x00"), "\x00"); strings.Join(got, "|") != strings.Join(words, "|") {
+			t.Errorf("%s received %q", shell, got)
+		}
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my dir; rm -rf x")
+	os.Mkdir(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "a b.txt"), []byte("one\ntwo\n"), 0644)
+
+	cmd := NewShellCommand("cd", dir).And(NewShellCommand("cat", "a b.txt").Pipe(NewShellCommand("wc").Arg("-l")))
+	if cmd.String() != "cd "+Quote(dir)+" && cat 'a b.txt' | wc -l" {
+		t.Errorf("the command is %s", cmd)
+	}
+	if res := Bash(cmd.String()); strings.TrimSpace(res.Stdout) != "2" {
+		t.Errorf("%s returned %+v", cmd, res)
+	}
+
+	// a chain is grouped in a pipeline
+	chain := NewShellCommand("echo", "a").And(NewShellCommand("echo", "b"))
+	cmd = chain.Pipe(NewShellCommand("wc", "-l"))
+	if cmd.String() != "{ echo a && echo b; } | wc -l" {
+		t.Errorf("the command is %s", cmd)
+	}
+	if res := Bash(cmd.String()); strings.TrimSpace(res.Stdout) != "2" {
+		t.Errorf("%s returned %q", cmd, res.Stdout)
+	}
+
+	cmd = NewShellCommand("false").Or(NewShellCommand("echo", "fallback"))
+	if res := Bash(cmd.String()); res.Stdout != "fallback\n" {
+		t.Errorf("%s returned %q", cmd, res.Stdout)
+	}
+	cmd = NewShellCommand("true").And(NewShellCommand("false").Or(NewShellCommand("echo", "x")))
+	if cmd.String() != "true && { false || echo x; }" {
+		t.Errorf("the command is %s", cmd)
+	}
+}
+
+func TestExec(t *testing.T) {
+	res := Exec("printf", "%s|%s", "a b", "$HOME;`id`")
+	if res.Stdout != "a b|$HOME;`id`" || !res.Success() {
+		t.Errorf("Exec returned %+v", res)
+	}
+	if res.Cmd != `printf '%s|%s' 'a b' '$HOME;`+"`id`'" {
+		t.Errorf("Exec reported the command %s", res.Cmd)
+	}
+
+	res = Exec("sh", "-c", "exit 4")
+	if res.ExitCode != 4 || res.Success() {
+		t.Errorf("Exec returned the exit code %d", res.ExitCode)
+	}
+
+	res = Exec(filepath.Join(t.TempDir(), "missing"))
+	if res.Err == nil || res.ExitCode != -1 {
+		t.Errorf("Exec of a missing executable returned %+v", res)
+	}
+
+	res = Runner{Dir: t.TempDir(), Input: "in"}.Exec("cat")
+	if res.Stdout != "in" {
+		t.Errorf("Runner.Exec returned %q", res.Stdout)
+	}
+}
@@ -0,0 +1,295 @@
+package gocli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Returned by lineEditor.readLine when the user presses Ctrl-C
+var errInterrupt = errors.New("Interrupted")
+
+// Completes the text before the cursor. Returns the replacement of that text
+// and the candidates to list if the completion is ambiguous
+type lineCompleter func(before string) (string, []string)
+
+// A minimal line editor for terminals in raw mode. It supports moving the
+// cursor, editing, the history and tab completion
+type lineEditor struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// Oldest entry first
+	history []string
+
+	complete lineCompleter
+
+	// The line being edited and the position of the cursor in it
+	line []rune
+	pos  int
+}
+
+func newLineEditor(in io.Reader, out io.Writer) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(in), out: out}
+}
+
+// Add an entry to the history, unless it repeats the last one. Returns true
+// if the entry was added
+func (e *lineEditor) addHistory(entry string) bool {
+	if entry == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == entry) {
+		return false
+	}
+	e.history = append(e.history, entry)
+	return true
+}
+
+// Redraw the prompt and the line, and place the cursor
+func (e *lineEditor) refresh(prompt string) {
+	b := &strings.Builder{}
+	b.WriteString("\r" + prompt + string(e.line) + "\x1b[K")
+	if back := displayWidth(string(e.line[e.pos:])); back > 0 {
+		fmt.Fprintf(b, "\x1b[%dD", back)
+	}
+	io.WriteString(e.out, b.String())
+}
+
+// Replace the line, e.g. with an entry of the history
+func (e *lineEditor) setLine(line string) {
+	e.line = []rune(line)
+	e.pos = len(e.line)
+}
+
+// Read an escape sequence after ESC and return a name for the key, e.g.
+// "up" or "delete". Unknown sequences return ""
+func (e *lineEditor) readEscape() (string, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return "", err
+	}
+
+	switch r {
+	case 'O':
+		// SS3 sequences, e.g. ESC O H
+		r, _, err = e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		return map[rune]string{'H': "home", 'F': "end", 'A': "up", 'B': "down", 'C': "right", 'D': "left"}[r], nil
+	case '[':
+		// CSI sequences, e.g. ESC [ A or ESC [ 3 ~
+		params := ""
+		for {
+			r, _, err = e.in.ReadRune()
+			if err != nil {
+				return "", err
+			}
+			if r >= 0x40 && r <= 0x7e {
+				break
+			}
+			params += string(r)
+		}
+		switch r {
+		case 'A':
+			return "up", nil
+		case 'B':
+			return "down", nil
+		case 'C':
+			return "right", nil
+		case 'D':
+			return "left", nil
+		case 'H':
+			return "home", nil
+		case 'F':
+			return "end", nil
+		case '~':
+			return map[string]string{"1": "home", "7": "home", "4": "end", "8": "end", "3": "delete"}[params], nil
+		}
+	case 'b':
+		return "word-left", nil
+	case 'f':
+		return "word-right", nil
+	}
+	return "", nil
+}
+
+// Returns the position of the start of the word before the cursor
+func (e *lineEditor) wordStart() int {
+	i := e.pos
+	for i > 0 && e.line[i-1] == ' ' {
+		i--
+	}
+	for i > 0 && e.line[i-1] != ' ' {
+		i--
+	}
+	return i
+}
+
+// Returns the position of the end of the word after the cursor
+func (e *lineEditor) wordEnd() int {
+	i := e.pos
+	for i < len(e.line) && e.line[i] == ' ' {
+		i++
+	}
+	for i < len(e.line) && e.line[i] != ' ' {
+		i++
+	}
+	return i
+}
+
+// Complete the text before the cursor and list the candidates if the
+// completion is ambiguous
+func (e *lineEditor) tab(prompt string) {
+	if e.complete == nil {
+		return
+	}
+	before := string(e.line[:e.pos])
+	replacement, candidates := e.complete(before)
+
+	after := e.line[e.pos:]
+	e.line = append([]rune(replacement), after...)
+	e.pos = len([]rune(replacement))
+
+	if len(candidates) > 1 {
+		io.WriteString(e.out, "\r\n"+strings.ReplaceAll(listColumns(candidates, terminalWidth()), "\n", "\r\n")+"\r\n")
+	}
+	e.refresh(prompt)
+}
+
+// Read a line, with the prompt. Returns io.EOF when the user presses Ctrl-D
+// on an empty line and errInterrupt when they press Ctrl-C
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	e.line, e.pos = []rune{}, 0
+	// the history is browsed from the end, where the new line is
+	index := len(e.history)
+	pending := ""
+
+	e.refresh(prompt)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(e.line) > 0 {
+				io.WriteString(e.out, "\r\n")
+				return string(e.line), nil
+			}
+			return "", err
+		}
+
+		key := ""
+		switch r {
+		case '\r', '\n':
+			io.WriteString(e.out, "\r\n")
+			return string(e.line), nil
+		case 1: // Ctrl-A
+			key = "home"
+		case 2: // Ctrl-B
+			key = "left"
+		case 3: // Ctrl-C
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupt
+		case 4: // Ctrl-D
+			if len(e.line) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			key = "delete"
+		case 5: // Ctrl-E
+			key = "end"
+		case 6: // Ctrl-F
+			key = "right"
+		case '\t':
+			e.tab(prompt)
+			continue
+		case 11: // Ctrl-K
+			e.line = e.line[:e.pos]
+		case 12: // Ctrl-L
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case 14: // Ctrl-N
+			key = "down"
+		case 16: // Ctrl-P
+			key = "up"
+		case 21: // Ctrl-U
+			e.line = e.line[e.pos:]
+			e.pos = 0
+		case 23: // Ctrl-W
+			start := e.wordStart()
+			e.line = append(e.line[:start], e.line[e.pos:]...)
+			e.pos = start
+		case 127, 8: // Backspace
+			if e.pos > 0 {
+				e.line = append(e.line[:e.pos-1], e.line[e.pos:]...)
+				e.pos--
+			}
+		case 27: // ESC
+			if key, err = e.readEscape(); err != nil {
+				return "", err
+			}
+		default:
+			if r >= 32 {
+				e.line = append(e.line[:e.pos], append([]rune{r}, e.line[e.pos:]...)...)
+				e.pos++
+			}
+		}
+
+		switch key {
+		case "home":
+			e.pos = 0
+		case "end":
+			e.pos = len(e.line)
+		case "left":
+			if e.pos > 0 {
+				e.pos--
+			}
+		case "right":
+			if e.pos < len(e.line) {
+				e.pos++
+			}
+		case "word-left":
+			e.pos = e.wordStart()
+		case "word-right":
+			e.pos = e.wordEnd()
+		case "delete":
+			if e.pos < len(e.line) {
+				e.line = append(e.line[:e.pos], e.line[e.pos+1:]...)
+			}
+		case "up":
+			if index > 0 {
+				if index == len(e.history) {
+					pending = string(e.line)
+				}
+				index--
+				e.setLine(e.history[index])
+			}
+		case "down":
+			if index < len(e.history) {
+				index++
+				if index == len(e.history) {
+					e.setLine(pending)
+				} else {
+					e.setLine(e.history[index])
+				}
+			}
+		}
+		e.refresh(prompt)
+	}
+}
+
+// Arrange words in columns which fit in width
+func listColumns(words []string, width int) string {
+	colWidth := 0
+	for _, word := range words {
+		colWidth = max(displayWidth(word)+2, colWidth)
+	}
+	perLine := max(width/max(colWidth, 1), 1)
+
+	lines := []string{}
+	for i := 0; i < len(words); i += perLine {
+		line := ""
+		for j := i; j < i+perLine && j < len(words); j++ {
+			line += paddedName(words[j], colWidth)
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	return strings.Join(lines, "\n")
+}
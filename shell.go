@@ -0,0 +1,315 @@
+package gocli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Configuration of the interactive shell
+type ShellOptions struct {
+	// Printed before every line, "<root>> " by default
+	Prompt string
+
+	// Printed before the lines which continue a statement, "> " by default
+	ContinuationPrompt string
+
+	// File the history is kept in between sessions, "~/.<root>_history" by
+	// default. Set it to os.DevNull to keep the history in memory only
+	HistoryFile string
+
+	// Number of entries kept in the history file, 1000 by default
+	HistorySize int
+}
+
+// Fill in the defaults
+func (o ShellOptions) withDefaults(cli *Cli) ShellOptions {
+	if o.Prompt == "" {
+		o.Prompt = cli.Entrypoint.Name + "> "
+	}
+	if o.ContinuationPrompt == "" {
+		o.ContinuationPrompt = "> "
+	}
+	if o.HistoryFile == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			o.HistoryFile = filepath.Join(home, "."+cli.funcName()+"_history")
+		}
+	}
+	if o.HistorySize <= 0 {
+		o.HistorySize = 1000
+	}
+	return o
+}
+
+// Add the built-in "shell" command, which starts an interactive shell
+func (cli *Cli) EnableShell(options ShellOptions) {
+	cli.shell = &options
+}
+
+// Run an interactive shell in which commands are entered without the name of
+// the root command, e.g. "run -n 3 ~/projects". Errors of the commands are
+// printed and do not end the session, which ends with "exit", "quit" or
+// Ctrl-D. When stdin is not a terminal, the commands are read from it line
+// by line, without prompts
+func (cli *Cli) RunShell(options ShellOptions) error {
+	interactive := term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+	return cli.runShell(os.Stdin, os.Stdout, interactive, options)
+}
+
+func (cli *Cli) runShell(in io.Reader, out io.Writer, interactive bool, options ShellOptions) error {
+	cli.addBuiltins()
+	options = options.withDefaults(cli)
+
+	cli.inShell = true
+	defer func() { cli.inShell = false }()
+
+	// Ctrl-C interrupts the command which runs, not the shell. The processes
+	// which the command started with Bash receive it from the terminal, and
+	// the rest with Context.Ctx
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	editor := newLineEditor(in, out)
+	editor.complete = cli.completeLine
+	editor.history = loadHistory(options.HistoryFile, options.HistorySize)
+
+	var readLine func(prompt string) (string, error)
+	if interactive {
+		fd := int(os.Stdin.Fd())
+		readLine = func(prompt string) (string, error) {
+			state, err := term.MakeRaw(fd)
+			if err != nil {
+				return "", err
+			}
+			defer term.Restore(fd, state)
+			return editor.readLine(prompt)
+		}
+	} else {
+		reader := bufio.NewReader(in)
+		readLine = func(prompt string) (string, error) {
+			line, err := reader.ReadString('\n')
+			if err == io.EOF && line != "" {
+				err = nil
+			}
+			return strings.TrimRight(line, "\r\n"), err
+		}
+	}
+
+	for {
+		statement, err := readStatement(readLine, options.Prompt, options.ContinuationPrompt)
+		if err == errInterrupt {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		words, err := splitCommandLine(statement)
+		if err != nil {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		if len(words) == 0 {
+			continue
+		}
+
+		// statements which span several lines are recorded as one line
+		entry := strings.Join(strings.Fields(statement), " ")
+		if editor.addHistory(entry) {
+			appendHistory(options.HistoryFile, entry)
+		}
+
+		if words[0] == "exit" || words[0] == "quit" {
+			return nil
+		}
+		if err := cli.runShellCommand(words, interrupts); err != nil {
+			fmt.Fprintln(out, err)
+		}
+	}
+}
+
+// Read a statement, which continues on the next line if the line ends with a
+// backslash or a quote is left open
+func readStatement(readLine func(prompt string) (string, error), prompt string, continuation string) (string, error) {
+	statement := ""
+	for {
+		line, err := readLine(prompt)
+		if err != nil {
+			if err == io.EOF && statement != "" {
+				return statement, nil
+			}
+			return "", err
+		}
+		prompt = continuation
+
+		// a backslash-newline continues the line
+		trailing := len(line) - len(strings.TrimRight(line, `\`))
+		if trailing%2 == 1 {
+			statement += line[:len(line)-1]
+			continue
+		}
+
+		statement += line
+		if _, err := splitCommandLine(statement); err != nil {
+			// the newline is part of the quoted word
+			statement += "\n"
+			continue
+		}
+		return statement, nil
+	}
+}
+
+// Run a command of the shell. Panics are returned as errors so that they do
+// not end the session, and an interrupt cancels the Context.Ctx of the command
+func (cli *Cli) runShellCommand(words []string, interrupts <-chan os.Signal) (err error) {
+	// the interrupts from before the command, e.g. while reading it, are
+	// not meant for it
+	select {
+	case <-interrupts:
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		cancel()
+		cli.ctx = nil
+	}()
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-done:
+		}
+	}()
+	cli.ctx = ctx
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Panic: %v", r)
+		}
+	}()
+	return cli.run(words)
+}
+
+// Complete the text before the cursor in the shell. Returns the replacement
+// of the text and the candidates if there are several
+func (cli *Cli) completeLine(before string) (string, []string) {
+	// the word being completed starts after the last space
+	start := strings.LastIndexAny(before, " \t") + 1
+	words, err := splitCommandLine(before[:start])
+	if err != nil {
+		return before, nil
+	}
+	partial := before[start:]
+
+	completions, directive := cli.complete(append(words, partial))
+	values := completionValues(completions)
+	if directive&(CompletionFiles|CompletionDirs) != 0 || (len(values) == 0 && directive&CompletionNoFiles == 0) {
+		values = completeFiles(partial, directive&CompletionDirs != 0)
+	}
+
+	switch len(values) {
+	case 0:
+		return before, nil
+	case 1:
+		suffix := " "
+		if directive&CompletionNoSpace != 0 || strings.HasSuffix(values[0], string(filepath.Separator)) {
+			suffix = ""
+		}
+		return before[:start] + values[0] + suffix, nil
+	}
+	return before[:start] + commonPrefix(values), values
+}
+
+// Returns the paths which start with prefix. Directories end with a
+// separator
+func completeFiles(prefix string, dirsOnly bool) []string {
+	matches, _ := filepath.Glob(prefix + "*")
+	paths := []string{}
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			paths = append(paths, match+string(filepath.Separator))
+		} else if !dirsOnly {
+			paths = append(paths, match)
+		}
+	}
+	return paths
+}
+
+// Returns the longest prefix which the strings have in common
+func commonPrefix(values []string) string {
+	prefix := []rune(values[0])
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, string(prefix)) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return string(prefix)
+}
+
+// Read the last "size" entries of a history file, and drop the older entries
+// from the file
+func loadHistory(file string, size int) []string {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return []string{}
+	}
+	entries := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	if len(entries) == 1 && entries[0] == "" {
+		return []string{}
+	}
+	if len(entries) > size {
+		entries = entries[len(entries)-size:]
+		os.WriteFile(file, []byte(strings.Join(entries, "\n")+"\n"), 0600)
+	}
+	return entries
+}
+
+// Append an entry to a history file
+func appendHistory(file string, entry string) {
+	if file == "" {
+		return
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, entry)
+}
+
+// The built-in "shell" command
+func (cli *Cli) shellCommand() *Command {
+	return &Command{
+		Name:      "shell",
+		ShortDesc: "Start an interactive shell",
+		LongDesc: "Start a shell in which the commands of " + cli.Entrypoint.Name + " are entered without '" + cli.Entrypoint.Name +
+			"', e.g. 'help'. The shell ends with 'exit', 'quit' or Ctrl-D.",
+		action: func(ctx Context) error {
+			if _, err := ParseArgs(ctx.Options, Argument{}, ctx.StrArgs); err != nil {
+				return err
+			}
+			if cli.inShell {
+				return fmt.Errorf("Already in the shell.")
+			}
+			return cli.RunShell(*cli.shell)
+		},
+	}
+}
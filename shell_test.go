@@ -0,0 +1,199 @@
+package gocli
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func shellCli(calls *[]string) Cli {
+	root := Command{Name: "root"}
+	echo := Command{
+		Name:     "echo",
+		Options:  &[]Option{{Short: "u", Long: "upper", Type: "bool"}},
+		Argument: Argument{Name: "text", Required: true},
+		Behavior: func(ctx Context) {
+			text := ctx.Args["text"].(string)
+			if ctx.Args["upper"].(bool) {
+				text = strings.ToUpper(text)
+			}
+			*calls = append(*calls, text)
+		},
+	}
+	boom := Command{Name: "boom", Behavior: func(ctx Context) { panic("boom") }}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &echo)
+	cli.AddChild(&root, &boom)
+	cli.EnableShell(ShellOptions{})
+	return cli
+}
+
+func TestRunShell(t *testing.T) {
+	calls := []string{}
+	cli := shellCli(&calls)
+	history := filepath.Join(t.TempDir(), "history")
+
+	input := "echo hello\n" +
+		"\n" +
+		"echo --nope x\n" +
+		"boom\n" +
+		"echo -u \\\n" +
+		"  world\n" +
+		"echo 'two\n" +
+		"lines'\n" +
+		"shell\n" +
+		"exit\n" +
+		"echo never\n"
+
+	out := &strings.Builder{}
+	err := cli.runShell(strings.NewReader(input), out, false, ShellOptions{HistoryFile: history})
+	if err != nil {
+		t.Errorf("runShell returned an error: %s", err)
+	}
+
+	// errors and panics do not end the session
+	if !reflect.DeepEqual(calls, []string{"hello", "WORLD", "two\nlines"}) {
+		t.Errorf("the shell ran %q", calls)
+	}
+	for _, msg := range []string{"Unexpected option `--nope`", "Panic: boom", "Already in the shell."} {
+		if !strings.Contains(out.String(), msg) {
+			t.Errorf("the shell did not print %q:\n%s", msg, out)
+		}
+	}
+
+	b, _ := os.ReadFile(history)
+	expected := "echo hello\necho --nope x\nboom\necho -u world\necho 'two lines'\nshell\nexit\n"
+	if string(b) != expected {
+		t.Errorf("the history file contains\n%s\nExpected\n%s", b, expected)
+	}
+
+	// the history is trimmed to its size
+	if entries := loadHistory(history, 2); !reflect.DeepEqual(entries, []string{"shell", "exit"}) {
+		t.Errorf("loadHistory returned %q", entries)
+	}
+	if b, _ := os.ReadFile(history); string(b) != "shell\nexit\n" {
+		t.Errorf("loadHistory did not trim the file: %q", b)
+	}
+}
+
+func TestRunShellInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts cannot be sent to a process on Windows")
+	}
+
+	var res BashResult
+	root := Command{Name: "root"}
+	hang := Command{Name: "hang", Behavior: func(ctx Context) {
+		// Ctrl-C while the command runs
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			p, _ := os.FindProcess(os.Getpid())
+			p.Signal(os.Interrupt)
+		}()
+		res = BashContext(ctx.Ctx, "sleep 10")
+	}}
+	cli := NewCli(&root)
+	cli.AddChild(&root, &hang)
+
+	start := time.Now()
+	out := &strings.Builder{}
+	if err := cli.runShell(strings.NewReader("hang\nexit\n"), out, false, ShellOptions{}); err != nil {
+		t.Errorf("runShell returned an error: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second || !errors.Is(res.Err, context.Canceled) {
+		t.Errorf("the interrupt did not cancel the command after %s: %+v", elapsed, res)
+	}
+}
+
+func TestLineEditor(t *testing.T) {
+	setTerminalWidth(t, 80)
+
+	read := func(e *lineEditor, keys string) (string, error) {
+		e.in.Reset(strings.NewReader(keys))
+		return e.readLine("> ")
+	}
+	e := newLineEditor(strings.NewReader(""), io.Discard)
+
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"abc\r", "abc"},
+		{"ac\x1b[Db\r", "abc"},
+		{"bc\x01a\x05d\r", "abcd"},
+		{"abc\x7f\x7fx\r", "ax"},
+		{"abc\x1b[D\x1b[D\x1b[3~\r", "ac"},
+		{"one two\x17three\r", "one three"},
+		{"one two\x1bb\x0b\r", "one "},
+		{"日本\x1b[Dx\r", "日x本"},
+	}
+	for _, test := range tests {
+		if line, err := read(e, test.keys); err != nil || line != test.expected {
+			t.Errorf("readLine(%q) returned %q, %v. Expected %q", test.keys, line, err, test.expected)
+		}
+	}
+
+	// history
+	e.history = []string{"first", "second"}
+	if line, _ := read(e, "\x1b[A\x1b[A\r"); line != "first" {
+		t.Errorf("up did not go back in the history: %q", line)
+	}
+	if line, _ := read(e, "new\x10\x0e\r"); line != "new" {
+		t.Errorf("down did not restore the new line: %q", line)
+	}
+
+	if _, err := read(e, "abc\x03"); err != errInterrupt {
+		t.Errorf("Ctrl-C returned %v", err)
+	}
+	if _, err := read(e, "\x04"); err != io.EOF {
+		t.Errorf("Ctrl-D returned %v", err)
+	}
+
+	// tab completion
+	e.complete = func(before string) (string, []string) {
+		return before + "pletion ", nil
+	}
+	if line, _ := read(e, "com\t--x\r"); line != "completion --x" {
+		t.Errorf("tab did not complete the line: %q", line)
+	}
+}
+
+func TestCompleteLine(t *testing.T) {
+	calls := []string{}
+	cli := shellCli(&calls)
+	cli.addBuiltins()
+
+	tests := []struct {
+		before      string
+		replacement string
+		candidates  []string
+	}{
+		{"ec", "echo ", nil},
+		{"echo --u", "echo --upper ", nil},
+		{"completion ", "completion ", []string{"bash", "zsh", "fish", "powershell"}},
+		{"completion z", "completion zsh ", nil},
+		{"co", "completion ", nil},
+		{"nothing --x", "nothing --x", nil},
+	}
+	for _, test := range tests {
+		replacement, candidates := cli.completeLine(test.before)
+		if replacement != test.replacement || !reflect.DeepEqual(candidates, test.candidates) {
+			t.Errorf("completeLine(%q) returned %q, %q. Expected %q, %q", test.before, replacement, candidates, test.replacement, test.candidates)
+		}
+	}
+
+	// ambiguous candidates are completed to their common prefix
+	if replacement, candidates := cli.completeLine("s"); replacement != "shell " || candidates != nil {
+		t.Errorf("completeLine(\"s\") returned %q, %q", replacement, candidates)
+	}
+	if prefix := commonPrefix([]string{"deploy", "describe", "delete"}); prefix != "de" {
+		t.Errorf("commonPrefix returned %q", prefix)
+	}
+}
@@ -0,0 +1,488 @@
+package gocli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A named step of a build, like a target of a Makefile. A task runs a shell
+// command or a Go function after the tasks it depends on. A task with
+// neither only runs its dependencies
+type Task struct {
+	// Name of the task and of its command (see TaskSet.AddCommands)
+	Name string
+
+	// A short description, shown in the help strings
+	Description string
+
+	// Names of the tasks which run before this one. Independent tasks run in
+	// the order they were added, not in the order of Deps
+	Deps []string
+
+	// Shell command which the task runs, with TaskSet.Runner
+	Cmd string
+
+	// Go function which the task runs instead of Cmd
+	Func func(ctx context.Context) error
+
+	// Glob patterns of the files which the task reads and writes, e.g.
+	// "src/*.go" and "bin/app". Directories stand for the files in them. A
+	// task with outputs is skipped if it is up to date: its outputs exist
+	// and none of its inputs was modified after them (see Hash). Tasks
+	// without outputs always run, unless Hash is set
+	Inputs  []string
+	Outputs []string
+
+	// Skip the task if the contents of its inputs and its Cmd did not change
+	// since it last succeeded (and its outputs, if any, exist), instead of
+	// comparing modification times. The hashes are kept in TaskSet.HashFile
+	Hash bool
+}
+
+// Tasks which depend on each other
+type TaskSet struct {
+	// Maximum number of tasks which run at the same time, 1 by default. A
+	// task starts once its dependencies are done
+	Jobs int
+
+	// Run the tasks even if they are up to date
+	Force bool
+
+	// Keep running the tasks which do not depend on a failed task. Otherwise
+	// the first failure cancels the running tasks
+	ContinueOnError bool
+
+	// Runner of the commands of the tasks, e.g. with a Dir or Env. The
+	// inputs and outputs of the tasks are relative to its Dir
+	Runner Runner
+
+	// File in which the hashes of the tasks with Hash are kept,
+	// ".gocli-tasks.json" in the Dir of the Runner by default
+	HashFile string
+
+	tasks []*Task
+}
+
+// Add tasks to the set. Their dependencies may be added later
+func (set *TaskSet) Add(tasks ...*Task) error {
+	for _, task := range tasks {
+		if _, ok := set.lookup(task.Name); ok {
+			return fmt.Errorf("Duplicate task '%s'.", task.Name)
+		}
+		if task.Cmd != "" && task.Func != nil {
+			return fmt.Errorf("Task '%s' has both a command and a function.", task.Name)
+		}
+		set.tasks = append(set.tasks, task)
+	}
+	return nil
+}
+
+// Returns the index of the task with the name
+func (set *TaskSet) lookup(name string) (int, bool) {
+	for i, task := range set.tasks {
+		if task.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Returns the indexes of the tasks with the names and of the tasks they
+// depend on, in the order they can run in
+func (set *TaskSet) sort(names []string) (Graph, []int, error) {
+	g := Graph{Adj: make([][]bool, len(set.tasks))}
+	for i, task := range set.tasks {
+		g.Adj[i] = make([]bool, len(set.tasks))
+		for _, dep := range task.Deps {
+			j, ok := set.lookup(dep)
+			if !ok {
+				return g, nil, fmt.Errorf("Task '%s' depends on the unknown task '%s'.", task.Name, dep)
+			}
+			g.Adj[i][j] = true
+		}
+	}
+
+	roots := []int{}
+	for _, name := range names {
+		i, ok := set.lookup(name)
+		if !ok {
+			return g, nil, fmt.Errorf("Unknown task '%s'.", name)
+		}
+		roots = append(roots, i)
+	}
+
+	order, cycle := g.sortTopologically(roots)
+	if cycle != nil {
+		path := []string{}
+		for _, i := range cycle {
+			path = append(path, set.tasks[i].Name)
+		}
+		return g, nil, fmt.Errorf("Cyclic dependency between tasks: %s.", strings.Join(path, " -> "))
+	}
+	return g, order, nil
+}
+
+// Run the tasks with the names and the tasks they depend on, each once. A
+// task runs after its dependencies succeeded (or were up to date), and is
+// skipped if one of them did not. The commands stream their output, labeled
+// with the name of the task if several tasks can run at the same time. When
+// ctx is done, the running commands are killed and the remaining tasks are
+// skipped. Returns the results in the order the tasks can run in, and an
+// error if a task failed
+func (set *TaskSet) Run(ctx context.Context, names ...string) (JobResults, error) {
+	g, order, err := set.sort(names)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	hashes, err := set.loadHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	const (
+		pending = iota
+		running
+		finished
+	)
+	state := make([]int, len(order))
+	position := map[int]int{}
+	results := make(JobResults, len(order))
+	for pos, i := range order {
+		task := set.tasks[i]
+		position[i] = pos
+		results[pos] = JobResult{Name: task.Name, Status: JobSkipped, BashResult: BashResult{Cmd: task.Cmd, ExitCode: -1}}
+	}
+
+	// start the tasks whose dependencies are done, in order, whenever a job
+	// is free
+	done := make(chan int)
+	jobs, busy := max(set.Jobs, 1), 0
+	for {
+		for pos, i := range order {
+			if state[pos] != pending {
+				continue
+			}
+			ready, blocked := true, ctx.Err() != nil
+			for j, edge := range g.Adj[i] {
+				if !edge {
+					continue
+				}
+				dep := position[j]
+				if state[dep] != finished {
+					ready = false
+				} else if status := results[dep].Status; status != JobSucceeded && status != JobUpToDate {
+					blocked = true
+				}
+			}
+			if blocked {
+				// the task stays skipped
+				state[pos] = finished
+				continue
+			}
+			if !ready || busy == jobs {
+				continue
+			}
+
+			state[pos] = running
+			busy++
+			go func(pos int, task *Task) {
+				results[pos] = set.runTask(ctx, task, hashes)
+				done <- pos
+			}(pos, set.tasks[i])
+		}
+
+		if busy == 0 {
+			break
+		}
+		pos := <-done
+		busy--
+		state[pos] = finished
+		if results[pos].Status == JobFailed && !set.ContinueOnError {
+			cancel()
+		}
+	}
+
+	for _, res := range results {
+		if res.Status == JobFailed {
+			return results, fmt.Errorf("Task '%s' failed: %s", res.Name, res.Err)
+		}
+	}
+	if err := parent.Err(); err != nil {
+		return results, contextError(err)
+	}
+	return results, nil
+}
+
+// Run a task unless it is up to date
+func (set *TaskSet) runTask(ctx context.Context, task *Task, hashes *taskHashes) JobResult {
+	result := JobResult{Name: task.Name, BashResult: BashResult{Cmd: task.Cmd, ExitCode: -1}}
+
+	hash := ""
+	if task.Hash {
+		var err error
+		if hash, err = set.hash(task); err != nil {
+			result.Status, result.Err = JobFailed, err
+			return result
+		}
+	}
+	if !set.Force {
+		upToDate, err := set.upToDate(task, hash, hashes)
+		if err != nil {
+			result.Status, result.Err = JobFailed, err
+			return result
+		}
+		if upToDate {
+			result.Status = JobUpToDate
+			return result
+		}
+	}
+
+	switch {
+	case task.Func != nil:
+		result.StartedAt = time.Now()
+		result.Err = task.Func(ctx)
+		result.Duration = time.Since(result.StartedAt)
+		if result.Err == nil {
+			result.ExitCode = 0
+		}
+	case task.Cmd != "":
+		result.BashResult = set.runner(task).RunContext(ctx, task.Cmd)
+	default:
+		result.ExitCode = 0
+	}
+
+	result.Status = jobStatus(ctx, result.BashResult)
+	if result.Status == JobSucceeded && task.Hash {
+		if err := hashes.set(task.Name, hash); err != nil {
+			result.Status, result.Err = JobFailed, err
+		}
+	}
+	return result
+}
+
+// Returns the runner of a task's command, which streams its output
+func (set *TaskSet) runner(task *Task) Runner {
+	r := set.Runner
+	r.StreamStdout, r.StreamStderr = true, true
+	if set.Jobs > 1 && r.Label == "" {
+		r.Label = "[" + task.Name + "] "
+	}
+	return r
+}
+
+// Returns the files which the patterns match, relative to the Dir of the
+// runner. The files in matching directories are included
+func (set *TaskSet) files(patterns []string) ([]string, error) {
+	files := []string{}
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(set.Runner.Dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pattern '%s': %s", pattern, err)
+		}
+		for _, match := range matches {
+			err := filepath.WalkDir(match, func(path string, entry fs.DirEntry, err error) error {
+				if err == nil && !entry.IsDir() {
+					files = append(files, path)
+				}
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Returns true if the outputs of the task exist and, depending on Hash, the
+// hash of the task did not change or none of its inputs was modified after
+// its outputs
+func (set *TaskSet) upToDate(task *Task, hash string, hashes *taskHashes) (bool, error) {
+	if len(task.Outputs) == 0 && !task.Hash {
+		return false, nil
+	}
+
+	// every pattern of the outputs has to match
+	var oldest time.Time
+	for _, pattern := range task.Outputs {
+		outputs, err := set.files([]string{pattern})
+		if err != nil || len(outputs) == 0 {
+			return false, err
+		}
+		for _, output := range outputs {
+			info, err := os.Stat(output)
+			if err != nil {
+				return false, err
+			}
+			if oldest.IsZero() || info.ModTime().Before(oldest) {
+				oldest = info.ModTime()
+			}
+		}
+	}
+
+	if task.Hash {
+		return hashes.get(task.Name) == hash, nil
+	}
+
+	inputs, err := set.files(task.Inputs)
+	if err != nil {
+		return false, err
+	}
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return false, err
+		}
+		if info.ModTime().After(oldest) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Returns a hash of the command of a task and the names and contents of its
+// inputs
+func (set *TaskSet) hash(task *Task) (string, error) {
+	inputs, err := set.files(task.Inputs)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", task.Cmd)
+	for _, input := range inputs {
+		f, err := os.Open(input)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00", input)
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// The hashes of the tasks when they last succeeded, kept in a file
+type taskHashes struct {
+	lock   sync.Mutex
+	file   string
+	hashes map[string]string
+}
+
+// Read the hashes of the tasks. A missing file has no hashes
+func (set *TaskSet) loadHashes() (*taskHashes, error) {
+	file := set.HashFile
+	if file == "" {
+		file = filepath.Join(set.Runner.Dir, ".gocli-tasks.json")
+	}
+	hashes := &taskHashes{file: file, hashes: map[string]string{}}
+
+	b, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return hashes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &hashes.hashes); err != nil {
+		return nil, fmt.Errorf("Invalid hash file '%s': %s", file, err)
+	}
+	return hashes, nil
+}
+
+func (h *taskHashes) get(name string) string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.hashes[name]
+}
+
+// Set the hash of a task and write the file
+func (h *taskHashes) set(name string, hash string) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.hashes[name] = hash
+	b, err := json.MarshalIndent(h.hashes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.file, append(b, '\n'), 0644)
+}
+
+// Add a command below parent for every task, which runs the task and its
+// dependencies. The commands take the options "-j, --jobs" (see Jobs) and
+// "-f, --force" (see Force)
+func (set *TaskSet) AddCommands(cli *Cli, parent *Command) error {
+	for _, task := range set.tasks {
+		if err := cli.AddChild(parent, set.taskCommand(task)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The command of a task
+func (set *TaskSet) taskCommand(task *Task) *Command {
+	long := task.Description
+	if len(task.Deps) > 0 {
+		// the description is a sentence of its own, e.g. "Build the binary."
+		if long != "" && !strings.HasSuffix(long, ".") {
+			long += "."
+		}
+		long = strings.TrimSpace(long + " Runs after: " + strings.Join(task.Deps, ", ") + ".")
+	}
+	return &Command{
+		Name:      task.Name,
+		ShortDesc: task.Description,
+		LongDesc:  long,
+		Options: &[]Option{
+			{Short: "j", Long: "jobs", Type: "int", Description: "Maximum number of tasks which run at the same time"},
+			{Short: "f", Long: "force", Type: "bool", Description: "Run the tasks even if they are up to date"},
+		},
+		action: func(ctx Context) error {
+			args, err := ParseArgs(ctx.Options, Argument{}, ctx.StrArgs)
+			if err != nil {
+				return err
+			}
+
+			run := *set
+			if jobs, ok := args["jobs"].(int); ok {
+				run.Jobs = jobs
+			}
+			if args["force"] == true {
+				run.Force = true
+			}
+
+			// Ctrl-C kills the commands, which run in their own process
+			// groups. The shell handles it with ctx.Ctx
+			runCtx, stop := signal.NotifyContext(ctx.Ctx, os.Interrupt)
+			defer stop()
+			_, err = run.Run(runCtx, task.Name)
+			return err
+		},
+	}
+}
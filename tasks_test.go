@@ -0,0 +1,253 @@
+package gocli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Returns a task set whose tasks record the order they ran in
+func recordingTasks(t *testing.T, tasks ...*Task) (*TaskSet, *[]string) {
+	ran := []string{}
+	var lock sync.Mutex
+	set := &TaskSet{Runner: Runner{Dir: t.TempDir()}}
+	for _, task := range tasks {
+		if task.Func == nil && task.Cmd == "" {
+			name := task.Name
+			task.Func = func(ctx context.Context) error {
+				lock.Lock()
+				defer lock.Unlock()
+				ran = append(ran, name)
+				return nil
+			}
+		}
+		if err := set.Add(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return set, &ran
+}
+
+func TestTaskSetOrder(t *testing.T) {
+	set, ran := recordingTasks(t,
+		&Task{Name: "all", Deps: []string{"test", "build"}},
+		&Task{Name: "build", Deps: []string{"generate"}},
+		&Task{Name: "test", Deps: []string{"generate"}},
+		&Task{Name: "generate"},
+		&Task{Name: "unrelated"},
+	)
+
+	results, err := set.Run(context.Background(), "all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(*ran, " ") != "generate build test all" {
+		t.Errorf("the tasks ran in the order %q", *ran)
+	}
+	if jobStatuses(results) != "generate:succeeded build:succeeded test:succeeded all:succeeded" {
+		t.Errorf("Run returned %s", jobStatuses(results))
+	}
+
+	if err := set.Add(&Task{Name: "build"}); err == nil || err.Error() != "Duplicate task 'build'." {
+		t.Errorf("Add returned the error %v for a duplicate", err)
+	}
+	if _, err := set.Run(context.Background(), "deploy"); err == nil || err.Error() != "Unknown task 'deploy'." {
+		t.Errorf("Run returned the error %v for an unknown task", err)
+	}
+}
+
+func TestTaskSetCycle(t *testing.T) {
+	set, _ := recordingTasks(t,
+		&Task{Name: "a", Deps: []string{"b"}},
+		&Task{Name: "b", Deps: []string{"c"}},
+		&Task{Name: "c", Deps: []string{"a"}},
+	)
+	_, err := set.Run(context.Background(), "a")
+	if err == nil || err.Error() != "Cyclic dependency between tasks: a -> b -> c -> a." {
+		t.Errorf("Run returned the error %v", err)
+	}
+
+	set, _ = recordingTasks(t, &Task{Name: "a", Deps: []string{"missing"}})
+	_, err = set.Run(context.Background(), "a")
+	if err == nil || err.Error() != "Task 'a' depends on the unknown task 'missing'." {
+		t.Errorf("Run returned the error %v", err)
+	}
+}
+
+func TestGraphSortTopologically(t *testing.T) {
+	// 0 -> 1 -> 2, 0 -> 2, 3 is not reachable
+	g := Graph{Adj: [][]bool{
+		{false, true, true, false},
+		{false, false, true, false},
+		{false, false, false, false},
+		{false, false, false, false},
+	}}
+	order, cycle := g.sortTopologically([]int{0})
+	if cycle != nil || len(order) != 3 || order[0] != 2 || order[1] != 1 || order[2] != 0 {
+		t.Errorf("sortTopologically returned %v, %v", order, cycle)
+	}
+
+	g.Adj[2][2] = true
+	if _, cycle := g.sortTopologically([]int{0}); len(cycle) != 2 || cycle[0] != 2 || cycle[1] != 2 {
+		t.Errorf("sortTopologically returned the cycle %v", cycle)
+	}
+}
+
+func TestTaskSetFailure(t *testing.T) {
+	set, ran := recordingTasks(t,
+		&Task{Name: "all", Deps: []string{"fail", "other"}},
+		&Task{Name: "fail", Cmd: "exit 3"},
+		&Task{Name: "other"},
+	)
+	results, err := set.Run(context.Background(), "all")
+	if err == nil || !strings.HasPrefix(err.Error(), "Task 'fail' failed: ") {
+		t.Errorf("Run returned the error %v", err)
+	}
+	if jobStatuses(results) != "fail:failed other:skipped all:skipped" || len(*ran) != 0 {
+		t.Errorf("Run returned %s and ran %q", jobStatuses(results), *ran)
+	}
+
+	set.ContinueOnError = true
+	results, _ = set.Run(context.Background(), "all")
+	if jobStatuses(results) != "fail:failed other:succeeded all:skipped" {
+		t.Errorf("Run returned %s with ContinueOnError", jobStatuses(results))
+	}
+
+	set, _ = recordingTasks(t, &Task{Name: "func", Func: func(ctx context.Context) error { return errors.New("broken") }})
+	if _, err := set.Run(context.Background(), "func"); err == nil || err.Error() != "Task 'func' failed: broken" {
+		t.Errorf("Run returned the error %v", err)
+	}
+}
+
+func TestTaskSetJobs(t *testing.T) {
+	set, _ := recordingTasks(t,
+		&Task{Name: "all", Deps: []string{"a", "b", "c"}},
+		&Task{Name: "a", Cmd: "sleep 0.2"},
+		&Task{Name: "b", Cmd: "sleep 0.2"},
+		&Task{Name: "c", Cmd: "sleep 0.2"},
+	)
+	set.Jobs = 3
+
+	start := time.Now()
+	if _, err := set.Run(context.Background(), "all"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= 600*time.Millisecond {
+		t.Errorf("3 tasks of 200ms with 3 jobs took %s", elapsed)
+	}
+}
+
+func TestTaskSetUpToDate(t *testing.T) {
+	set, _ := recordingTasks(t, &Task{
+		Name:    "build",
+		Cmd:     "cat in.txt > out.txt",
+		Inputs:  []string{"in.txt"},
+		Outputs: []string{"out.txt"},
+	})
+	dir := set.Runner.Dir
+	write := func(name string, content string, age time.Duration) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		when := time.Now().Add(-age)
+		os.Chtimes(path, when, when)
+	}
+	status := func() string {
+		results, err := set.Run(context.Background(), "build")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(results[0].Status)
+	}
+
+	write("in.txt", "one", time.Hour)
+	if s := status(); s != "succeeded" {
+		t.Errorf("the task without an output was %s", s)
+	}
+	if s := status(); s != "up-to-date" {
+		t.Errorf("the task with an output newer than the input was %s", s)
+	}
+
+	write("in.txt", "two", 0)
+	os.Chtimes(filepath.Join(dir, "out.txt"), time.Now().Add(-time.Minute), time.Now().Add(-time.Minute))
+	if s := status(); s != "succeeded" {
+		t.Errorf("the task with a modified input was %s", s)
+	}
+
+	set.Force = true
+	if s := status(); s != "succeeded" {
+		t.Errorf("the forced task was %s", s)
+	}
+}
+
+func TestTaskSetHash(t *testing.T) {
+	set, ran := recordingTasks(t, &Task{Name: "test", Inputs: []string{"src"}, Hash: true})
+	dir := set.Runner.Dir
+	os.Mkdir(filepath.Join(dir, "src"), 0755)
+	os.WriteFile(filepath.Join(dir, "src", "a.go"), []byte("package a"), 0644)
+
+	run := func() {
+		if _, err := set.Run(context.Background(), "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run()
+	run()
+	if len(*ran) != 1 {
+		t.Errorf("the task with unchanged inputs ran %d times", len(*ran))
+	}
+
+	// a new modification time alone does not matter
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(dir, "src", "a.go"), later, later)
+	run()
+	if len(*ran) != 1 {
+		t.Errorf("the task with touched inputs ran %d times", len(*ran))
+	}
+
+	os.WriteFile(filepath.Join(dir, "src", "b.go"), []byte("package a"), 0644)
+	run()
+	if len(*ran) != 2 {
+		t.Errorf("the task with a new input ran %d times", len(*ran))
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".gocli-tasks.json")); err != nil {
+		t.Errorf("the hashes were not written: %s", err)
+	}
+}
+
+func TestTaskSetCommands(t *testing.T) {
+	dir := t.TempDir()
+	set := &TaskSet{Runner: Runner{Dir: dir}}
+	set.Add(
+		&Task{Name: "build", Description: "Build the binary", Deps: []string{"generate"}, Cmd: "echo built >> log"},
+		&Task{Name: "generate", Description: "Generate the code", Cmd: "echo generated >> log"},
+	)
+
+	root := &Command{Name: "make"}
+	cli := NewCli(root)
+	if err := set.AddCommands(&cli, root); err != nil {
+		t.Fatal(err)
+	}
+
+	captureStdout(t, func() {
+		if err := cli.run([]string{"build"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	b, _ := os.ReadFile(filepath.Join(dir, "log"))
+	if string(b) != "generated\nbuilt\n" {
+		t.Errorf("the build command ran %q", b)
+	}
+
+	help := captureStdout(t, func() { cli.run([]string{"build", "--help"}) })
+	if !strings.Contains(help, "Build the binary. Runs after: generate.") || !strings.Contains(help, "--jobs") {
+		t.Errorf("the help string of the build command is\n%s", help)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package gocli
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Returns the width of the terminal attached to stdout, or 0 if stdout is
+// not a terminal
+func ttyWidth() int {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(ws.Col)
+}
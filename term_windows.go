@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package gocli
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Returns the width of the console attached to stdout, or 0 if stdout is
+// not a console
+func ttyWidth() int {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(os.Stdout.Fd()), &info); err != nil {
+		return 0
+	}
+	return int(info.Window.Right-info.Window.Left) + 1
+}
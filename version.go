@@ -0,0 +1,153 @@
+package gocli
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Override the build metadata which is read from the binary, e.g.
+//
+//	go build -ldflags "-X github.com/aSquidsBody/gocli.Version=v1.2.3 -X github.com/aSquidsBody/gocli.BuildTime=$(date -u +%FT%TZ)"
+var (
+	Version   string
+	Revision  string
+	BuildTime string
+)
+
+// Build metadata of the CLI, printed by "--version" and the version command
+type VersionInfo struct {
+	// Version of the main module, e.g. "v1.2.3" or "(devel)"
+	Version string `json:"version"`
+
+	// VCS revision the binary was built from
+	Revision string `json:"revision,omitempty"`
+
+	// Whether the working tree had uncommitted changes
+	Dirty bool `json:"dirty"`
+
+	// Time of the build: the time of the VCS revision, unless BuildTime is
+	// set
+	BuildTime string `json:"buildTime,omitempty"`
+
+	GoVersion string `json:"goVersion"`
+
+	// e.g. "linux/amd64"
+	Platform string `json:"platform"`
+}
+
+// Reads the build info of the binary. It is a variable so that tests can
+// replace it
+var readBuildInfo = debug.ReadBuildInfo
+
+var VersionOption = Option{
+	Description: "Print the version",
+	Long:        "version",
+	Required:    false,
+	Type:        "bool",
+}
+
+// Add the "--version" option to the entrypoint and the "version" command to
+// the CLI. The version is read from the build info of the binary and can be
+// overridden with ldflags (see Version)
+func (cli *Cli) EnableVersion() {
+	cli.version = true
+}
+
+// Returns the build metadata of the binary, with the ldflags overrides
+func (cli *Cli) versionInfo() VersionInfo {
+	info := VersionInfo{
+		Version:   "unknown",
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if build, ok := readBuildInfo(); ok {
+		if build.Main.Version != "" {
+			info.Version = build.Main.Version
+		}
+		applyBuildSettings(&info, build)
+	}
+
+	if Version != "" {
+		info.Version = Version
+	}
+	if Revision != "" {
+		info.Revision = Revision
+	}
+	if BuildTime != "" {
+		info.BuildTime = BuildTime
+	}
+	return info
+}
+
+// Returns the version as text, e.g.
+//
+//	example v1.2.3
+//	Revision:    0f9fa26af87c (dirty)
+//	Build time:  2021-06-30T00:52:30Z
+//	Go version:  go1.18
+//	Platform:    linux/amd64
+func (v VersionInfo) Text(name string) string {
+	lines := []string{name + " " + v.Version}
+	field := func(key string, value string) {
+		if value != "" {
+			lines = append(lines, paddedName(key+":", 13)+value)
+		}
+	}
+
+	revision := v.Revision
+	if revision != "" && v.Dirty {
+		revision += " (dirty)"
+	}
+	field("Revision", revision)
+	field("Build time", v.BuildTime)
+	field("Go version", v.GoVersion)
+	field("Platform", v.Platform)
+
+	return strings.Join(lines, Sep())
+}
+
+// The built-in "version" command
+func (cli *Cli) versionCommand() *Command {
+	return &Command{
+		Name:      "version",
+		ShortDesc: "Print the version",
+		LongDesc:  "Print the version of " + cli.Entrypoint.Name + " and the metadata of its build.",
+		Options: &[]Option{
+			{
+				Short:       "o",
+				Long:        "output",
+				Type:        "string",
+				Description: "Output format: 'text' or 'json'",
+				Complete: CompleteWith(func(ctx Context, toComplete string) ([]Completion, CompletionDirective) {
+					return []Completion{{Value: "text"}, {Value: "json"}}, CompletionNoFiles
+				}),
+			},
+		},
+		action: func(ctx Context) error {
+			args, err := ParseArgs(ctx.Options, Argument{}, ctx.StrArgs)
+			if err != nil {
+				return err
+			}
+
+			info := cli.versionInfo()
+			output, _ := args["output"].(string)
+			switch output {
+			case "", "text":
+				fmt.Println(info.Text(cli.Entrypoint.Name))
+			case "json":
+				b, err := json.MarshalIndent(info, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(b))
+			default:
+				return fmt.Errorf("Invalid output format '%s'. Expected 'text' or 'json'.", output)
+			}
+			return nil
+		},
+	}
+}
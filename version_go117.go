@@ -0,0 +1,10 @@
+//go:build !go1.18
+// +build !go1.18
+
+package gocli
+
+import "runtime/debug"
+
+// Binaries built before Go 1.18 record no VCS settings, and runtime.Version
+// is the Go version
+func applyBuildSettings(info *VersionInfo, build *debug.BuildInfo) {}
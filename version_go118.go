@@ -0,0 +1,27 @@
+//go:build go1.18
+// +build go1.18
+
+package gocli
+
+import (
+	"runtime/debug"
+	"strconv"
+)
+
+// Add the Go version and the VCS settings of the build, which binaries built
+// with Go 1.18 or later record
+func applyBuildSettings(info *VersionInfo, build *debug.BuildInfo) {
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.modified":
+			info.Dirty, _ = strconv.ParseBool(setting.Value)
+		case "vcs.time":
+			info.BuildTime = setting.Value
+		}
+	}
+	if build.GoVersion != "" {
+		info.GoVersion = build.GoVersion
+	}
+}
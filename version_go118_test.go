@@ -0,0 +1,45 @@
+//go:build go1.18
+// +build go1.18
+
+package gocli
+
+import (
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestVersionInfo(t *testing.T) {
+	setBuildInfo(t, &debug.BuildInfo{
+		GoVersion: "go1.18",
+		Main:      debug.Module{Path: "example.com/root", Version: "v1.2.3"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "0f9fa26af87c"},
+			{Key: "vcs.modified", Value: "true"},
+			{Key: "vcs.time", Value: "2021-06-30T00:52:30Z"},
+		},
+	})
+	cli := versionCli()
+
+	info := cli.versionInfo()
+	if info.Version != "v1.2.3" || info.Revision != "0f9fa26af87c" || !info.Dirty || info.BuildTime != "2021-06-30T00:52:30Z" || info.GoVersion != "go1.18" {
+		t.Errorf("versionInfo returned %+v", info)
+	}
+
+	text := strings.ReplaceAll(info.Text("root"), Sep(), "\n")
+	expected := "root v1.2.3\n" +
+		"Revision:    0f9fa26af87c (dirty)\n" +
+		"Build time:  2021-06-30T00:52:30Z\n" +
+		"Go version:  go1.18\n"
+	if !strings.HasPrefix(text, expected) {
+		t.Errorf("Text returned\n%s\nExpected\n%s", text, expected)
+	}
+
+	// ldflags take precedence
+	Version, BuildTime = "v2.0.0", "today"
+	defer func() { Version, BuildTime = "", "" }()
+	info = cli.versionInfo()
+	if info.Version != "v2.0.0" || info.BuildTime != "today" || info.Revision != "0f9fa26af87c" {
+		t.Errorf("versionInfo did not apply the overrides: %+v", info)
+	}
+}
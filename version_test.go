@@ -0,0 +1,51 @@
+package gocli
+
+import (
+	"encoding/json"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+// Replace the build info of the binary for the duration of a test
+func setBuildInfo(t *testing.T, info *debug.BuildInfo) {
+	original := readBuildInfo
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return info, info != nil }
+	t.Cleanup(func() { readBuildInfo = original })
+}
+
+func versionCli() Cli {
+	root := Command{Name: "root", Behavior: func(ctx Context) {}}
+	cli := NewCli(&root)
+	cli.EnableVersion()
+	cli.addBuiltins()
+	return cli
+}
+
+func TestVersionCommand(t *testing.T) {
+	setBuildInfo(t, &debug.BuildInfo{Main: debug.Module{Version: "v1.2.3"}})
+	cli := versionCli()
+
+	var err error
+	for _, args := range [][]string{{"--version"}, {"version"}, {"version", "-o", "text"}} {
+		out := captureStdout(t, func() { err = cli.run(args) })
+		if err != nil || !strings.HasPrefix(out, "root v1.2.3"+Sep()) {
+			t.Errorf("cli.run(%q) printed %q, err: %v", args, out, err)
+		}
+	}
+
+	out := captureStdout(t, func() { err = cli.run([]string{"version", "--output", "json"}) })
+	info := VersionInfo{}
+	if err != nil || json.Unmarshal([]byte(out), &info) != nil || info.Version != "v1.2.3" {
+		t.Errorf("version --output json printed %q, err: %v", out, err)
+	}
+
+	if err = cli.run([]string{"version", "--output=yaml"}); err == nil {
+		t.Errorf("version accepted an invalid output format")
+	}
+
+	// "--version" is only an option of the entrypoint
+	if err = cli.run([]string{"help", "--version"}); err == nil {
+		t.Errorf("help accepted --version")
+	}
+}
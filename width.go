@@ -0,0 +1,164 @@
+package gocli
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Width used when the terminal width cannot be detected
+const defaultTerminalWidth = 80
+
+// Returns the width of the terminal, falling back to $COLUMNS and then to 80
+// columns. It is a variable so that tests can fix the width
+var terminalWidth = func() int {
+	if w := ttyWidth(); w > 0 {
+		return w
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	return defaultTerminalWidth
+}
+
+// ANSI escape sequences, e.g. colors (CSI) and hyperlinks (OSC)
+var ansiRegexp = regexp.MustCompile("\x1b\\[[0-?]*[ -/]*[@-~]|\x1b\\][^\x07\x1b]*(\x07|\x1b\\\\)")
+
+// Remove the ANSI escape sequences from a string
+func stripANSI(s string) string {
+	return ansiRegexp.ReplaceAllString(s, "")
+}
+
+// Ranges of the characters which take two columns in a terminal (East Asian
+// wide and fullwidth characters, and emoji)
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},
+	{0x231A, 0x231B},
+	{0x2329, 0x232A},
+	{0x23E9, 0x23EC},
+	{0x23F0, 0x23F0},
+	{0x23F3, 0x23F3},
+	{0x25FD, 0x25FE},
+	{0x2614, 0x2615},
+	{0x2648, 0x2653},
+	{0x267F, 0x267F},
+	{0x2693, 0x2693},
+	{0x26A1, 0x26A1},
+	{0x26AA, 0x26AB},
+	{0x26BD, 0x26BE},
+	{0x26C4, 0x26C5},
+	{0x26CE, 0x26CE},
+	{0x26D4, 0x26D4},
+	{0x26EA, 0x26EA},
+	{0x26F2, 0x26F3},
+	{0x26F5, 0x26F5},
+	{0x26FA, 0x26FA},
+	{0x26FD, 0x26FD},
+	{0x2705, 0x2705},
+	{0x270A, 0x270B},
+	{0x2728, 0x2728},
+	{0x274C, 0x274C},
+	{0x274E, 0x274E},
+	{0x2753, 0x2755},
+	{0x2757, 0x2757},
+	{0x2795, 0x2797},
+	{0x27B0, 0x27B0},
+	{0x27BF, 0x27BF},
+	{0x2B1B, 0x2B1C},
+	{0x2B50, 0x2B50},
+	{0x2B55, 0x2B55},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xA960, 0xA97F},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x16FE0, 0x16FE4},
+	{0x17000, 0x18CFF},
+	{0x1B000, 0x1B2FF},
+	{0x1F004, 0x1F004},
+	{0x1F0CF, 0x1F0CF},
+	{0x1F18E, 0x1F18E},
+	{0x1F191, 0x1F19A},
+	{0x1F200, 0x1F251},
+	{0x1F300, 0x1F64F},
+	{0x1F680, 0x1F6FF},
+	{0x1F7E0, 0x1F7EB},
+	{0x1F90C, 0x1F9FF},
+	{0x1FA70, 0x1FAFF},
+	{0x20000, 0x3FFFD},
+}
+
+// Returns the number of columns a character takes in a terminal
+func runeWidth(r rune) int {
+	if r == 0 || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || unicode.Is(unicode.Cf, r) || unicode.IsControl(r) {
+		return 0
+	}
+	for _, wide := range wideRanges {
+		if r < wide.lo {
+			break
+		}
+		if r <= wide.hi {
+			return 2
+		}
+	}
+	return 1
+}
+
+// Returns the number of columns a string takes in a terminal, ignoring ANSI
+// escape sequences
+func displayWidth(s string) (w int) {
+	for _, r := range stripANSI(s) {
+		w += runeWidth(r)
+	}
+	return
+}
+
+// Wrap text into lines of at most "width" columns. Every line but the first
+// is indented by "indent" spaces, so that the text can be placed in a column
+// which starts "indent" columns into the line. Existing line breaks are kept,
+// the lines of an indented paragraph keep its indentation, and words longer
+// than a line are not broken
+func wrapText(text string, width int, indent int) string {
+	available := width - indent
+	if available < 10 {
+		// too narrow to be readable, leave it to the terminal
+		return text
+	}
+
+	lines := []string{}
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if displayWidth(paragraph) <= available {
+			lines = append(lines, paragraph)
+			continue
+		}
+
+		// keep the indentation of the paragraph on every line
+		prefix := paragraph[:len(paragraph)-len(strings.TrimLeft(paragraph, " \t"))]
+		line, lineWidth := prefix, displayWidth(prefix)
+		for _, word := range strings.Fields(paragraph) {
+			wordWidth := displayWidth(word)
+			if strings.TrimSpace(line) != "" && lineWidth+1+wordWidth > available {
+				lines = append(lines, line)
+				line, lineWidth = prefix, displayWidth(prefix)
+			}
+			if strings.TrimSpace(line) != "" {
+				line += " "
+				lineWidth++
+			}
+			line += word
+			lineWidth += wordWidth
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n"+strings.Repeat(" ", indent))
+}
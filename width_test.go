@@ -0,0 +1,43 @@
+package gocli
+
+import "testing"
+
+func TestDisplayWidth(t *testing.T) {
+	tests := map[string]int{
+		"":                                     0,
+		"hello":                                5,
+		"\x1b[34mhello\x1b[0m":                 5,
+		"日本語":                                  6,
+		"cafe\u0301":                           4,
+		"\x1b]8;;http://x\x07link\x1b]8;;\x07": 4,
+		"🚀 go":                                 5,
+	}
+	for s, expected := range tests {
+		if w := displayWidth(s); w != expected {
+			t.Errorf("displayWidth(%q) returned %d. Expected %d", s, w, expected)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		text     string
+		width    int
+		indent   int
+		expected string
+	}{
+		{"short", 80, 10, "short"},
+		{"one two three four", 14, 4, "one two\n    three four"},
+		{"one two\nthree", 80, 2, "one two\n  three"},
+		{"  indented code line", 14, 0, "  indented\n  code line"},
+		{"  - one two three", 14, 2, "  - one two\n    three"},
+		{"averyveryverylongword x", 15, 0, "averyveryverylongword\nx"},
+		{"日本語 日本語 日本語", 24, 10, "日本語 日本語\n          日本語"},
+		{"too narrow to wrap", 12, 10, "too narrow to wrap"},
+	}
+	for _, test := range tests {
+		if wrapped := wrapText(test.text, test.width, test.indent); wrapped != test.expected {
+			t.Errorf("wrapText(%q, %d, %d) returned %q. Expected %q", test.text, test.width, test.indent, wrapped, test.expected)
+		}
+	}
+}